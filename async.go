@@ -0,0 +1,168 @@
+package scientist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+var (
+	// ErrAsyncDropped is reported when an AsyncPool's queue is full.
+	ErrAsyncDropped = errors.New("[scientist] async queue is full, experiment dropped")
+
+	// ErrAsyncShutdown is reported when an experiment is queued on an
+	// AsyncPool that has been shut down.
+	ErrAsyncShutdown = errors.New("[scientist] async pool is shut down, experiment dropped")
+)
+
+// DropPolicy decides which experiment an AsyncPool drops when its queue is
+// full.
+type DropPolicy int
+
+const (
+	// DropNewest drops the experiment that is being queued.
+	DropNewest DropPolicy = iota
+
+	// DropOldest drops the experiment that has been queued the longest to make
+	// room for the new one.
+	DropOldest
+)
+
+type asyncJob struct {
+	run  func()
+	drop func(error)
+}
+
+// AsyncPool runs experiment candidates in the background, so that experiments
+// return as soon as the control behavior has finished.
+type AsyncPool struct {
+	policy  DropPolicy
+	queue   chan asyncJob
+	mu      sync.Mutex
+	idle    *sync.Cond
+	pending int
+	closed  bool
+}
+
+func NewAsyncPool(workers, queueSize int, policy DropPolicy) *AsyncPool {
+	if workers < 1 {
+		workers = 1
+	}
+
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	p := &AsyncPool{
+		policy: policy,
+		queue:  make(chan asyncJob, queueSize),
+	}
+	p.idle = sync.NewCond(&p.mu)
+
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+
+	return p
+}
+
+func (p *AsyncPool) work() {
+	for job := range p.queue {
+		p.run(job)
+	}
+}
+
+// run runs a queued experiment. A panic in a callback that isn't recovered,
+// like ReportErrors, would otherwise crash the process from a worker.
+func (p *AsyncPool) run(job asyncJob) {
+	defer p.done()
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "[scientist] panic in async experiment: %v\n", r)
+		}
+	}()
+	job.run()
+}
+
+func (p *AsyncPool) done() {
+	p.mu.Lock()
+	p.pending -= 1
+	if p.pending == 0 {
+		p.idle.Broadcast()
+	}
+	p.mu.Unlock()
+}
+
+func (p *AsyncPool) submit(run func(), drop func(error)) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		drop(ErrAsyncShutdown)
+		return
+	}
+
+	job := asyncJob{run: run, drop: drop}
+	var dropped []asyncJob
+
+	select {
+	case p.queue <- job:
+		p.pending += 1
+	default:
+		if p.policy == DropOldest {
+			select {
+			case oldest := <-p.queue:
+				p.pending -= 1
+				dropped = append(dropped, oldest)
+			default:
+			}
+		}
+
+		select {
+		case p.queue <- job:
+			p.pending += 1
+		default:
+			dropped = append(dropped, job)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, job := range dropped {
+		job.drop(ErrAsyncDropped)
+	}
+}
+
+// Flush blocks until every queued experiment has finished.
+func (p *AsyncPool) Flush() {
+	p.mu.Lock()
+	for p.pending > 0 {
+		p.idle.Wait()
+	}
+	p.mu.Unlock()
+}
+
+// Shutdown stops the pool from accepting experiments, and waits for queued
+// experiments to finish. If ctx is done first, its error is returned and any
+// remaining experiments continue in the background.
+func (p *AsyncPool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	flushed := make(chan struct{})
+	go func() {
+		p.Flush()
+		close(flushed)
+	}()
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
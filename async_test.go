@@ -0,0 +1,203 @@
+package scientist
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func asyncExperiment(pool *AsyncPool, name string, release chan struct{}) *Experiment {
+	e := New(name)
+	e.Async = pool
+	e.Use(func() (interface{}, error) {
+		return 1, nil
+	})
+	e.Try(func() (interface{}, error) {
+		<-release
+		return 2, nil
+	})
+	return e
+}
+
+func TestAsync(t *testing.T) {
+	pool := NewAsyncPool(1, 1, DropNewest)
+	release := make(chan struct{})
+	e := asyncExperiment(pool, "async", release)
+
+	var published *Result
+	e.Publish(func(r Result) error {
+		published = &r
+		return nil
+	})
+
+	v, err := e.Run()
+	if v != 1 {
+		t.Errorf("Unexpected control value: %d", v)
+	}
+
+	if err != nil {
+		t.Errorf("Unexpected control error: %v", err)
+	}
+
+	close(release)
+	pool.Flush()
+
+	if published == nil {
+		t.Fatalf("results never published")
+	}
+
+	if !published.IsMismatched() {
+		t.Errorf("Expected mismatch")
+	}
+
+	if published.Control.Index != 0 || published.Candidates[0].Index != 1 {
+		t.Errorf("Expected control to run first, got indexes %d, %d", published.Control.Index, published.Candidates[0].Index)
+	}
+}
+
+func TestAsyncDropNewest(t *testing.T) {
+	pool := NewAsyncPool(1, 1, DropNewest)
+	release := make(chan struct{})
+	dropped := assertAsyncDrops(t, pool, release)
+	if dropped != "third" {
+		t.Errorf("Expected third experiment to be dropped, got %q", dropped)
+	}
+}
+
+func TestAsyncDropOldest(t *testing.T) {
+	pool := NewAsyncPool(1, 1, DropOldest)
+	release := make(chan struct{})
+	dropped := assertAsyncDrops(t, pool, release)
+	if dropped != "second" {
+		t.Errorf("Expected second experiment to be dropped, got %q", dropped)
+	}
+}
+
+// assertAsyncDrops runs 3 experiments on a pool with 1 worker and room for 1
+// queued experiment, and returns the name of the dropped experiment.
+func assertAsyncDrops(t *testing.T, pool *AsyncPool, release chan struct{}) string {
+	var mu sync.Mutex
+	var dropped []string
+	published := make(map[string]bool)
+	started := make(chan struct{})
+
+	for i, name := range []string{"first", "second", "third"} {
+		e := asyncExperiment(pool, name, release)
+		if i == 0 {
+			e.Try(func() (interface{}, error) {
+				close(started)
+				<-release
+				return 2, nil
+			})
+		}
+
+		e.Publish(func(r Result) error {
+			mu.Lock()
+			published[r.Experiment.Name] = true
+			mu.Unlock()
+			return nil
+		})
+
+		e.ReportErrors(func(errs ...ResultError) {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, err := range errs {
+				if err.Operation != "async" || err.Err != ErrAsyncDropped {
+					t.Errorf("Unexpected error: %q %v", err.Operation, err.Err)
+				}
+				dropped = append(dropped, err.Experiment)
+			}
+		})
+
+		e.Run()
+		if i == 0 {
+			<-started
+		}
+	}
+
+	close(release)
+	pool.Flush()
+
+	if len(dropped) != 1 {
+		t.Fatalf("Expected 1 dropped experiment, got %v", dropped)
+	}
+
+	if len(published) != 2 || published[dropped[0]] {
+		t.Errorf("Unexpected published experiments: %v", published)
+	}
+
+	return dropped[0]
+}
+
+func TestAsyncShutdown(t *testing.T) {
+	pool := NewAsyncPool(1, 1, DropNewest)
+	release := make(chan struct{})
+	e := asyncExperiment(pool, "async", release)
+	e.Run()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := pool.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected shutdown to time out, got: %v", err)
+	}
+
+	close(release)
+	if err := pool.Shutdown(context.Background()); err != nil {
+		t.Errorf("Unexpected shutdown error: %v", err)
+	}
+
+	reported := false
+	e = asyncExperiment(pool, "async", release)
+	e.ReportErrors(func(errs ...ResultError) {
+		for _, err := range errs {
+			if err.Err != ErrAsyncShutdown {
+				t.Errorf("Unexpected error: %v", err.Err)
+			}
+			reported = true
+		}
+	})
+
+	v, err := e.Run()
+	if v != 1 || err != nil {
+		t.Errorf("Unexpected control result: %v, %v", v, err)
+	}
+
+	if !reported {
+		t.Errorf("Expected dropped experiment to be reported")
+	}
+}
+
+func TestAsyncPanic(t *testing.T) {
+	pool := NewAsyncPool(1, 2, DropNewest)
+	for i := 0; i < 2; i++ {
+		e := New("async.panic")
+		e.Async = pool
+		e.Use(func() (interface{}, error) {
+			return 1, nil
+		})
+		e.Try(func() (interface{}, error) {
+			return 1, nil
+		})
+		e.Publish(func(Result) error {
+			return errors.New("publish")
+		})
+		e.ReportErrors(func(...ResultError) {
+			panic("report")
+		})
+		e.Run()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		pool.Flush()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected Flush to return after panics")
+	}
+}
@@ -0,0 +1,100 @@
+// Command scientist reports statistics for experiment results published by
+// scientist.JSONPublisher or scientist.MismatchStore.
+//
+//	scientist [-format text|json|markdown] [-top n] [file ...]
+//	scientist -tests package [-max-tests n] [file ...] > regressions_test.go
+//
+// Results are read from the given files, or stdin if no files are given. With
+// -tests, it writes a Go test file that replays the mismatched results with
+// scientisttest.ReplayAll instead of a report, for up to -max-tests mismatches
+// with a different control and Context per experiment.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/technoweenie/go-scientist/scientisttest"
+)
+
+func main() {
+	format := flag.String("format", "text", "output format: text, json, or markdown")
+	top := flag.Int("top", 5, "number of mismatch signatures to show per candidate")
+	tests := flag.String("tests", "", "write regression tests for this package instead of a report")
+	maxTests := flag.Int("max-tests", 100, "number of unique mismatches per experiment to write regression tests for")
+	flag.Parse()
+
+	write, ok := formats[*format]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown format: %q\n", *format)
+		os.Exit(2)
+	}
+
+	if *top < 0 || *maxTests < 0 {
+		fmt.Fprintln(os.Stderr, "-top and -max-tests can't be negative")
+		os.Exit(2)
+	}
+
+	a := newAnalyzer()
+	if *tests != "" {
+		a.maxMismatches = *maxTests
+	}
+
+	if err := readAll(a, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if a.invalid > 0 {
+		fmt.Fprintf(os.Stderr, "skipped %d invalid lines\n", a.invalid)
+	}
+
+	if *tests != "" {
+		write = func(w io.Writer, _ Report) error {
+			return scientisttest.WriteRegressionTests(w, *tests, a.mismatches)
+		}
+	}
+
+	if err := write(os.Stdout, a.report(*top)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func readAll(a *analyzer, paths []string) error {
+	if len(paths) == 0 {
+		return a.read(os.Stdin)
+	}
+
+	for _, path := range paths {
+		if err := readFile(a, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readFile(a *analyzer, path string) error {
+	if path == "-" {
+		return a.read(os.Stdin)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := a.read(f); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+var formats = map[string]func(io.Writer, Report) error{
+	"text":     writeText,
+	"json":     writeJSON,
+	"markdown": writeMarkdown,
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+var behaviorColumns = []string{"behavior", "count", "match", "ignore", "mismatch", "error", "p50", "p90", "p99"}
+
+func writeJSON(w io.Writer, report Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+func writeText(w io.Writer, report Report) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for i, exp := range report.Experiments {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+
+		fmt.Fprintf(tw, "experiment: %s (%d results)\n", exp.Name, exp.Results)
+		fmt.Fprintln(tw, strings.Join(behaviorColumns, "\t"))
+		for _, row := range behaviorRows(exp) {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+
+		for _, c := range exp.Candidates {
+			if len(c.Signatures) == 0 {
+				continue
+			}
+
+			fmt.Fprintf(tw, "\ntop mismatches for %s:\n", c.Name)
+			for _, sig := range c.Signatures {
+				fmt.Fprintf(tw, "%d\t%s\n", sig.Count, sig.Signature)
+			}
+		}
+	}
+	return tw.Flush()
+}
+
+func writeMarkdown(w io.Writer, report Report) error {
+	for i, exp := range report.Experiments {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+
+		fmt.Fprintf(w, "## %s\n\n%d results\n\n", exp.Name, exp.Results)
+		fmt.Fprintf(w, "| %s |\n", strings.Join(behaviorColumns, " | "))
+		fmt.Fprintf(w, "|%s\n", strings.Repeat(" --- |", len(behaviorColumns)))
+		for _, row := range behaviorRows(exp) {
+			fmt.Fprintf(w, "| %s |\n", strings.Join(row, " | "))
+		}
+
+		for _, c := range exp.Candidates {
+			if len(c.Signatures) == 0 {
+				continue
+			}
+
+			fmt.Fprintf(w, "\n### Top mismatches for %s\n\n| count | signature |\n| --- | --- |\n", c.Name)
+			for _, sig := range c.Signatures {
+				fmt.Fprintf(w, "| %d | `%s` |\n", sig.Count, sig.Signature)
+			}
+		}
+	}
+	return nil
+}
+
+// behaviorRows formats the control and candidate stats of an experiment as
+// table rows. The control has no match rates.
+func behaviorRows(exp ExperimentStats) [][]string {
+	rows := [][]string{
+		behaviorRow(exp.Control, "-", "-", "-"),
+	}
+
+	for _, c := range exp.Candidates {
+		rows = append(rows, behaviorRow(c.BehaviorStats, percent(c.MatchRate), percent(c.IgnoreRate), percent(c.MismatchRate)))
+	}
+	return rows
+}
+
+func behaviorRow(b BehaviorStats, match, ignore, mismatch string) []string {
+	return []string{
+		b.Name,
+		fmt.Sprintf("%d", b.Count),
+		match,
+		ignore,
+		mismatch,
+		percent(b.ErrorRate),
+		b.P50.String(),
+		b.P90.String(),
+		b.P99.String(),
+	}
+}
+
+func percent(rate float64) string {
+	return fmt.Sprintf("%.1f%%", rate*100)
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	scientist "github.com/technoweenie/go-scientist"
+)
+
+type Report struct {
+	Experiments []ExperimentStats `json:"experiments"`
+}
+
+type ExperimentStats struct {
+	Name       string           `json:"name"`
+	Results    int              `json:"results"`
+	Control    BehaviorStats    `json:"control"`
+	Candidates []CandidateStats `json:"candidates"`
+}
+
+type BehaviorStats struct {
+	Name      string        `json:"name"`
+	Count     int           `json:"count"`
+	Errors    int           `json:"errors"`
+	ErrorRate float64       `json:"error_rate"`
+	P50       time.Duration `json:"p50_ns"`
+	P90       time.Duration `json:"p90_ns"`
+	P99       time.Duration `json:"p99_ns"`
+}
+
+type CandidateStats struct {
+	BehaviorStats
+	Matched      int              `json:"matched"`
+	Ignored      int              `json:"ignored"`
+	Mismatched   int              `json:"mismatched"`
+	MatchRate    float64          `json:"match_rate"`
+	IgnoreRate   float64          `json:"ignore_rate"`
+	MismatchRate float64          `json:"mismatch_rate"`
+	Signatures   []SignatureCount `json:"signatures,omitempty"`
+}
+
+// SignatureCount is the number of mismatches with the same signature: the
+// paths that differed, with slice indexes and map keys replaced by "[*]".
+type SignatureCount struct {
+	Signature string `json:"signature"`
+	Count     int    `json:"count"`
+}
+
+type analyzer struct {
+	experiments map[string]*experimentData
+	invalid     int
+
+	// mismatches keeps up to maxMismatches mismatched records for each
+	// experiment, for -tests. None are kept by default. Records with the same
+	// control and Context replay the same test, so only the first is kept.
+	mismatches    []scientist.ResultRecord
+	maxMismatches int
+	seen          map[string]bool
+}
+
+type experimentData struct {
+	results     int
+	mismatches  int
+	controlName string
+	control     *behaviorData
+	candidates  map[string]*behaviorData
+}
+
+type behaviorData struct {
+	errors     int
+	runtimes   []time.Duration
+	statuses   map[string]int
+	signatures map[string]int
+}
+
+func newAnalyzer() *analyzer {
+	return &analyzer{
+		experiments: make(map[string]*experimentData),
+		seen:        make(map[string]bool),
+	}
+}
+
+// read adds every result record in r, skipping invalid lines.
+func (a *analyzer) read(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+
+		var rec scientist.ResultRecord
+		if err := json.Unmarshal(line, &rec); err != nil || rec.Experiment == "" {
+			a.invalid += 1
+			continue
+		}
+		a.add(rec)
+	}
+	return scanner.Err()
+}
+
+func (a *analyzer) add(rec scientist.ResultRecord) {
+	exp := a.experiments[rec.Experiment]
+	if exp == nil {
+		exp = &experimentData{
+			control:    newBehaviorData(),
+			candidates: make(map[string]*behaviorData),
+		}
+		a.experiments[rec.Experiment] = exp
+	}
+
+	if rec.Status == scientist.StatusMismatched && exp.mismatches < a.maxMismatches {
+		// json sorts the Context keys.
+		context, _ := json.Marshal(rec.Context)
+		key := rec.Experiment + "\x00" + rec.Control.Name + "\x00" + string(context)
+		if !a.seen[key] {
+			a.seen[key] = true
+			exp.mismatches += 1
+			a.mismatches = append(a.mismatches, rec)
+		}
+	}
+
+	exp.results += 1
+	exp.controlName = rec.Control.Name
+	exp.control.add(rec.Control)
+
+	for _, c := range rec.Candidates {
+		data := exp.candidates[c.Name]
+		if data == nil {
+			data = newBehaviorData()
+			exp.candidates[c.Name] = data
+		}
+		data.add(c)
+	}
+}
+
+func newBehaviorData() *behaviorData {
+	return &behaviorData{
+		statuses:   make(map[string]int),
+		signatures: make(map[string]int),
+	}
+}
+
+func (d *behaviorData) add(o scientist.ObservationRecord) {
+	if o.Error != "" {
+		d.errors += 1
+	}
+
+	d.runtimes = append(d.runtimes, o.Runtime)
+	d.statuses[o.Status] += 1
+
+	if o.Status == scientist.StatusMismatched {
+		d.signatures[signature(o.Diff)] += 1
+	}
+}
+
+func (a *analyzer) report(top int) Report {
+	names := make([]string, 0, len(a.experiments))
+	for name := range a.experiments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	report := Report{Experiments: make([]ExperimentStats, len(names))}
+	for i, name := range names {
+		exp := a.experiments[name]
+		stats := ExperimentStats{
+			Name:    name,
+			Results: exp.results,
+			Control: exp.control.stats(exp.controlName),
+		}
+
+		candidates := make([]string, 0, len(exp.candidates))
+		for cname := range exp.candidates {
+			candidates = append(candidates, cname)
+		}
+		sort.Strings(candidates)
+
+		for _, cname := range candidates {
+			stats.Candidates = append(stats.Candidates, exp.candidates[cname].candidateStats(cname, top))
+		}
+
+		report.Experiments[i] = stats
+	}
+
+	return report
+}
+
+func (d *behaviorData) stats(name string) BehaviorStats {
+	runtimes := append([]time.Duration(nil), d.runtimes...)
+	sort.Slice(runtimes, func(i, j int) bool {
+		return runtimes[i] < runtimes[j]
+	})
+
+	return BehaviorStats{
+		Name:      name,
+		Count:     len(runtimes),
+		Errors:    d.errors,
+		ErrorRate: rate(d.errors, len(runtimes)),
+		P50:       percentile(runtimes, 50),
+		P90:       percentile(runtimes, 90),
+		P99:       percentile(runtimes, 99),
+	}
+}
+
+func (d *behaviorData) candidateStats(name string, top int) CandidateStats {
+	stats := CandidateStats{
+		BehaviorStats: d.stats(name),
+		Matched:       d.statuses[scientist.StatusMatched],
+		Ignored:       d.statuses[scientist.StatusIgnored],
+		Mismatched:    d.statuses[scientist.StatusMismatched],
+	}
+	stats.MatchRate = rate(stats.Matched, stats.Count)
+	stats.IgnoreRate = rate(stats.Ignored, stats.Count)
+	stats.MismatchRate = rate(stats.Mismatched, stats.Count)
+
+	for sig, count := range d.signatures {
+		stats.Signatures = append(stats.Signatures, SignatureCount{sig, count})
+	}
+
+	sort.Slice(stats.Signatures, func(i, j int) bool {
+		a, b := stats.Signatures[i], stats.Signatures[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Signature < b.Signature
+	})
+
+	if len(stats.Signatures) > top {
+		stats.Signatures = stats.Signatures[:top]
+	}
+
+	return stats
+}
+
+// signature joins the sorted, unique paths of a diff.
+func signature(diff []scientist.DifferenceRecord) string {
+	if len(diff) == 0 {
+		return "(no diff)"
+	}
+
+	seen := make(map[string]bool, len(diff))
+	paths := make([]string, 0, len(diff))
+	for _, d := range diff {
+		path := scientist.WildcardPath(d.Path)
+		if path == "" {
+			path = "."
+		}
+
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+
+	sort.Strings(paths)
+	return strings.Join(paths, " ")
+}
+
+// percentile returns the nearest-rank percentile of sorted runtimes.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func rate(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
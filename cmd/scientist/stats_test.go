@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	scientist "github.com/technoweenie/go-scientist"
+)
+
+func publishResults(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	p := scientist.NewJSONPublisher(&buf)
+
+	for i := 0; i < 10; i++ {
+		i := i
+		e := scientist.New("users")
+		e.Context["user"] = strconv.Itoa((i - 1) / 2)
+		e.Use(func() (interface{}, error) {
+			return map[string]int{"a": 1}, nil
+		})
+		e.Try(func() (interface{}, error) {
+			switch {
+			case i < 5:
+				return map[string]int{"a": 1}, nil
+			case i < 8:
+				return map[string]int{"a": i}, nil
+			case i < 9:
+				return map[string]int{"b": 1}, nil
+			default:
+				return nil, errors.New("broken")
+			}
+		})
+		e.Publish(p.Publish)
+		e.Run()
+	}
+
+	buf.WriteString("not json\n\n")
+	return &buf
+}
+
+func TestAnalyzer(t *testing.T) {
+	a := newAnalyzer()
+	if err := a.read(publishResults(t)); err != nil {
+		t.Fatalf("Unexpected read error: %v", err)
+	}
+
+	if a.invalid != 1 {
+		t.Errorf("Expected 1 invalid line, got %d", a.invalid)
+	}
+
+	if len(a.mismatches) != 0 {
+		t.Errorf("Expected no mismatched records without -tests, got %d", len(a.mismatches))
+	}
+
+	report := a.report(2)
+	if len(report.Experiments) != 1 {
+		t.Fatalf("Unexpected experiments: %+v", report.Experiments)
+	}
+
+	exp := report.Experiments[0]
+	if exp.Name != "users" || exp.Results != 10 || exp.Control.Name != "control" || exp.Control.Count != 10 {
+		t.Errorf("Unexpected experiment stats: %+v", exp)
+	}
+
+	if len(exp.Candidates) != 1 {
+		t.Fatalf("Unexpected candidates: %+v", exp.Candidates)
+	}
+
+	c := exp.Candidates[0]
+	if c.Matched != 5 || c.Mismatched != 5 || c.MatchRate != 0.5 || c.ErrorRate != 0.1 {
+		t.Errorf("Unexpected candidate stats: %+v", c)
+	}
+
+	expected := []SignatureCount{
+		{`[*]`, 4},
+		{`(error)`, 1},
+	}
+	if len(c.Signatures) != len(expected) {
+		t.Fatalf("Unexpected signatures: %+v", c.Signatures)
+	}
+
+	for i, sig := range expected {
+		if c.Signatures[i] != sig {
+			t.Errorf("Expected signature %d to be %+v, got %+v", i, sig, c.Signatures[i])
+		}
+	}
+}
+
+func TestAnalyzerMismatches(t *testing.T) {
+	// the 5 mismatches are for 3 users.
+	for max, expected := range map[int]int{10: 3, 2: 2} {
+		a := newAnalyzer()
+		a.maxMismatches = max
+		if err := a.read(publishResults(t)); err != nil {
+			t.Fatalf("Unexpected read error: %v", err)
+		}
+
+		if len(a.mismatches) != expected {
+			t.Errorf("Expected %d mismatched records with a max of %d, got %d", expected, max, len(a.mismatches))
+		}
+
+		users := make(map[string]bool)
+		for _, rec := range a.mismatches {
+			users[rec.Context["user"]] = true
+		}
+
+		if len(users) != len(a.mismatches) {
+			t.Errorf("Expected mismatched records for different users: %v", users)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	runtimes := make([]time.Duration, 100)
+	for i := range runtimes {
+		runtimes[i] = time.Duration(i+1) * time.Millisecond
+	}
+
+	for p, expected := range map[int]time.Duration{50: 50 * time.Millisecond, 90: 90 * time.Millisecond, 99: 99 * time.Millisecond} {
+		if actual := percentile(runtimes, p); actual != expected {
+			t.Errorf("Expected p%d to be %v, got %v", p, expected, actual)
+		}
+	}
+
+	if actual := percentile(runtimes[:1], 99); actual != time.Millisecond {
+		t.Errorf("Unexpected percentile of 1 runtime: %v", actual)
+	}
+}
+
+func TestFormats(t *testing.T) {
+	a := newAnalyzer()
+	a.read(publishResults(t))
+	report := a.report(5)
+
+	expected := map[string][]string{
+		"text":     {"experiment: users (10 results)", "candidate", "50.0%", "top mismatches for candidate:", `[*]`},
+		"markdown": {"## users", "| behavior | count |", "| candidate | 10 | 50.0% |", "| 4 | `[*]` |"},
+		"json":     {`"name": "users"`, `"mismatch_rate": 0.5`, `"signature": "[*]"`},
+	}
+
+	for format, lines := range expected {
+		var buf bytes.Buffer
+		if err := formats[format](&buf, report); err != nil {
+			t.Errorf("Unexpected %s error: %v", format, err)
+		}
+
+		for _, line := range lines {
+			if !strings.Contains(buf.String(), line) {
+				t.Errorf("Expected %s output to contain %q:\n%s", format, line, buf.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package scientist
+
+import "errors"
+
+// Comparator compares a control and candidate value for an untyped
+// experiment. Use CompareTyped to use it with a typed experiment.
+type Comparator func(control, candidate interface{}) (bool, error)
+
+// Equal compares values like the default comparator, with the given options
+// to loosen the comparison:
+//
+//	experiment.Compare(scientist.Equal(
+//	  scientist.FloatTolerance(0.001, 0),
+//	  scientist.IgnorePaths(".UpdatedAt"),
+//	))
+func Equal(opts ...DiffOption) Comparator {
+	o := newDiffOptions(opts)
+	return func(control, candidate interface{}) (bool, error) {
+		return len(o.diff(control, candidate)) == 0, nil
+	}
+}
+
+// All matches if every comparator matches. It stops at the first mismatch or
+// error.
+func All(comparators ...Comparator) Comparator {
+	return func(control, candidate interface{}) (bool, error) {
+		for _, c := range comparators {
+			ok, err := c(control, candidate)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+}
+
+// Any matches if at least one comparator matches. If none match, the first
+// error is returned.
+func Any(comparators ...Comparator) Comparator {
+	return func(control, candidate interface{}) (bool, error) {
+		var firstErr error
+		for _, c := range comparators {
+			ok, err := c(control, candidate)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+
+			if ok {
+				return true, nil
+			}
+		}
+		return false, firstErr
+	}
+}
+
+// CompareTyped adapts a Comparator for a typed experiment's Compare callback.
+func CompareTyped[T any](c Comparator) func(control, candidate T) (bool, error) {
+	return func(control, candidate T) (bool, error) {
+		return c(control, candidate)
+	}
+}
+
+// CompareCleaned runs the experiment's Clean callback on both values before
+// comparing them. Clean errors are returned as comparison errors.
+func CompareCleaned[T any](e *TypedExperiment[T], c Comparator) func(control, candidate T) (bool, error) {
+	return func(control, candidate T) (bool, error) {
+		cleanedControl, err := e.cleaner(control)
+		if err != nil {
+			return false, err
+		}
+
+		cleanedCandidate, err := e.cleaner(candidate)
+		if err != nil {
+			return false, err
+		}
+
+		return c(cleanedControl, cleanedCandidate)
+	}
+}
+
+// ErrorComparator compares the errors from a control and candidate that both
+// returned one. Set it with an experiment's CompareErrors method.
+type ErrorComparator func(control, candidate error) (bool, error)
+
+// ErrorMessagesEqual matches errors with the same message. It's the default
+// error comparator.
+func ErrorMessagesEqual(control, candidate error) (bool, error) {
+	return control.Error() == candidate.Error(), nil
+}
+
+// ErrorsIs matches errors that both wrap the same target, checked with
+// errors.Is:
+//
+//	experiment.CompareErrors(scientist.ErrorsIs(sql.ErrNoRows, ErrNotFound))
+func ErrorsIs(targets ...error) ErrorComparator {
+	return func(control, candidate error) (bool, error) {
+		for _, target := range targets {
+			if errors.Is(control, target) && errors.Is(candidate, target) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// ErrorsAs matches errors that both wrap an error of type E, checked with
+// errors.As:
+//
+//	experiment.CompareErrors(scientist.ErrorsAs[*os.PathError]())
+func ErrorsAs[E error]() ErrorComparator {
+	return func(control, candidate error) (bool, error) {
+		var controlTarget, candidateTarget E
+		return errors.As(control, &controlTarget) && errors.As(candidate, &candidateTarget), nil
+	}
+}
+
+// BothErrored matches any two errors.
+func BothErrored(control, candidate error) (bool, error) {
+	return true, nil
+}
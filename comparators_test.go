@@ -0,0 +1,166 @@
+package scientist
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+type comparedUser struct {
+	Login   string
+	Score   float64
+	Seen    time.Time
+	Roles   []string
+	Version int `scientist:"ignore"`
+}
+
+func TestEqualFloatTolerance(t *testing.T) {
+	assertComparator(t, Equal(FloatTolerance(0.01, 0)), 1.0, 1.005, true)
+	assertComparator(t, Equal(FloatTolerance(0.01, 0)), 1.0, 1.02, false)
+	assertComparator(t, Equal(FloatTolerance(0, 0.1)), 100.0, 109.0, true)
+	assertComparator(t, Equal(FloatTolerance(0, 0.1)), 100.0, 120.0, false)
+	assertComparator(t, Equal(FloatTolerance(0.01, 0)),
+		comparedUser{Score: 1.0},
+		comparedUser{Score: 1.001},
+		true)
+}
+
+func TestEqualUnorderedSlices(t *testing.T) {
+	assertComparator(t, Equal(UnorderedSlices()), []int{1, 2, 3}, []int{3, 1, 2}, true)
+	assertComparator(t, Equal(UnorderedSlices()), []int{1, 2, 2}, []int{2, 1, 1}, false)
+	assertComparator(t, Equal(), []int{1, 2, 3}, []int{3, 1, 2}, false)
+	assertComparator(t, Equal(UnorderedSlices()),
+		comparedUser{Roles: []string{"admin", "staff"}},
+		comparedUser{Roles: []string{"staff", "admin"}},
+		true)
+
+	diff := DiffValues([]int{1, 2}, []int{2, 3}, UnorderedSlices())
+	if s := diff.String(); s != "[0]: 1 != <missing>\n[1]: <missing> != 3" {
+		t.Errorf("Unexpected diff:\n%s", s)
+	}
+}
+
+func TestEqualTimeTolerance(t *testing.T) {
+	now := time.Now()
+	assertComparator(t, Equal(TimeTolerance(time.Second)), now, now.Add(time.Millisecond), true)
+	assertComparator(t, Equal(TimeTolerance(time.Second)), now, now.Add(-time.Minute), false)
+	assertComparator(t, Equal(TimeTolerance(time.Second)), now, now.UTC(), true)
+	assertComparator(t, Equal(TimeTolerance(time.Second)),
+		comparedUser{Seen: now},
+		comparedUser{Seen: now.Add(time.Millisecond)},
+		true)
+}
+
+func TestEqualIgnoreFields(t *testing.T) {
+	control := []comparedUser{{Login: "alice", Score: 1, Version: 1}}
+	candidate := []comparedUser{{Login: "alice", Score: 2, Version: 2}}
+
+	assertComparator(t, Equal(), control, candidate, false)
+	assertComparator(t, Equal(IgnoreTag("scientist", "ignore")), control, candidate, false)
+	assertComparator(t, Equal(IgnorePaths("[*].Score")), control, candidate, false)
+	assertComparator(t, Equal(IgnorePaths("[0].Score"), IgnoreTag("scientist", "ignore")), control, candidate, true)
+	assertComparator(t, Equal(IgnorePaths("[*].Score", "[*].Version")), control, candidate, true)
+}
+
+func TestAllAndAny(t *testing.T) {
+	logins := func(control, candidate interface{}) (bool, error) {
+		return control.(comparedUser).Login == candidate.(comparedUser).Login, nil
+	}
+	broken := func(control, candidate interface{}) (bool, error) {
+		return false, errors.New("broken")
+	}
+
+	control := comparedUser{Login: "alice", Score: 1}
+	candidate := comparedUser{Login: "alice", Score: 1.001}
+
+	assertComparator(t, All(logins, Equal(FloatTolerance(0.01, 0))), control, candidate, true)
+	assertComparator(t, All(logins, Equal()), control, candidate, false)
+	assertComparator(t, Any(Equal(), logins), control, candidate, true)
+	assertComparator(t, Any(Equal(), broken, logins), control, candidate, true)
+
+	if ok, err := Any(Equal(), broken)(control, candidate); ok || err == nil || err.Error() != "broken" {
+		t.Errorf("Expected Any to return the broken error, got %v, %v", ok, err)
+	}
+
+	if ok, err := All(broken, logins)(control, candidate); ok || err == nil {
+		t.Errorf("Expected All to return the broken error, got %v, %v", ok, err)
+	}
+}
+
+func TestCompareTypedAndCleaned(t *testing.T) {
+	e := NewTyped[comparedUser]("cleaned")
+	e.Use(func() (comparedUser, error) {
+		return comparedUser{Login: "alice", Score: 1}, nil
+	})
+	e.Try(func() (comparedUser, error) {
+		return comparedUser{Login: "ALICE", Score: 2}, nil
+	})
+	e.Clean(func(u comparedUser) (interface{}, error) {
+		return strings.ToLower(u.Login), nil
+	})
+	e.ErrorOnMismatches = true
+
+	e.Compare(CompareTyped[comparedUser](Equal()))
+	if _, err := e.Run(); err == nil {
+		t.Errorf("Expected mismatch error")
+	}
+
+	e.Compare(CompareCleaned(e, Equal()))
+	if _, err := e.Run(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+type codeError struct {
+	Code int
+}
+
+func (e *codeError) Error() string {
+	return fmt.Sprintf("code %d", e.Code)
+}
+
+func TestErrorComparators(t *testing.T) {
+	errNotFound := errors.New("not found")
+	wrapped := fmt.Errorf("user 1: %w", errNotFound)
+	other := fmt.Errorf("user 2: %w", errNotFound)
+	code := fmt.Errorf("request 1: %w", &codeError{404})
+
+	assertErrorComparator(t, ErrorMessagesEqual, wrapped, fmt.Errorf("user 1: %w", errNotFound), true)
+	assertErrorComparator(t, ErrorMessagesEqual, wrapped, other, false)
+
+	assertErrorComparator(t, ErrorsIs(io.EOF, errNotFound), wrapped, other, true)
+	assertErrorComparator(t, ErrorsIs(io.EOF), wrapped, other, false)
+	assertErrorComparator(t, ErrorsIs(errNotFound), wrapped, code, false)
+
+	assertErrorComparator(t, ErrorsAs[*codeError](), code, &codeError{500}, true)
+	assertErrorComparator(t, ErrorsAs[*codeError](), code, wrapped, false)
+
+	assertErrorComparator(t, BothErrored, code, wrapped, true)
+}
+
+func assertErrorComparator(t *testing.T, c ErrorComparator, control, candidate error, expected bool) {
+	t.Helper()
+	ok, err := c(control, candidate)
+	if err != nil {
+		t.Errorf("Unexpected comparison error: %v", err)
+	}
+
+	if ok != expected {
+		t.Errorf("Expected comparison of %q and %q to be %v", control, candidate, expected)
+	}
+}
+
+func assertComparator(t *testing.T, c Comparator, control, candidate interface{}, expected bool) {
+	t.Helper()
+	ok, err := c(control, candidate)
+	if err != nil {
+		t.Errorf("Unexpected comparison error: %v", err)
+	}
+
+	if ok != expected {
+		t.Errorf("Expected comparison of %#v and %#v to be %v", control, candidate, expected)
+	}
+}
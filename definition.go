@@ -0,0 +1,123 @@
+package scientist
+
+import "context"
+
+// Definition is a reusable definition of an untyped experiment.
+type Definition = TypedDefinition[interface{}]
+
+// TypedDefinition is an experiment's name and callbacks, defined once and
+// reused by every call. It's safe to share between goroutines. Each call gets
+// its own trial from New, with its own behaviors and Context:
+//
+//	var permissions = scientist.Define("widget-permissions", func(e *scientist.Experiment) {
+//	  e.Compare(compareUsers)
+//	  e.Publish(publisher.Publish)
+//	})
+//
+//	func (w *Widget) Allows(u *User) (bool, error) {
+//	  e := permissions.New()
+//	  e.Use(...)
+//	  e.Try(...)
+//	  e.Context["user"] = u.Login
+//	  return scientist.Bool(e.Run())
+//	}
+type TypedDefinition[T any] struct {
+	template *TypedExperiment[T]
+}
+
+// Define creates an untyped experiment definition. The configure callback sets
+// up the callbacks and fields that every call shares. They can't be changed
+// afterwards, since each call's trial only has its own behaviors and Context.
+func Define(name string, configure func(e *Experiment)) *Definition {
+	return DefineTyped[interface{}](name, configure)
+}
+
+func DefineTyped[T any](name string, configure func(e *TypedExperiment[T])) *TypedDefinition[T] {
+	e := NewTyped[T](name)
+	if configure != nil {
+		configure(e)
+	}
+	return &TypedDefinition[T]{template: e}
+}
+
+func (d *TypedDefinition[T]) Name() string {
+	return d.template.Name
+}
+
+// New returns a trial for a single call. It starts with a copy of any
+// behaviors and Context values that were set when the experiment was defined.
+func (d *TypedDefinition[T]) New() *TypedTrial[T] {
+	t := &TypedTrial[T]{
+		Context:    make(map[string]string, len(d.template.Context)),
+		definition: d,
+	}
+
+	for key, value := range d.template.Context {
+		t.Context[key] = value
+	}
+
+	// behaviors are copied so that the template's never change, with room for
+	// a control and candidate.
+	if len(d.template.behaviors) > 0 {
+		t.behaviors = make([]behavior[T], len(d.template.behaviors), len(d.template.behaviors)+2)
+		copy(t.behaviors, d.template.behaviors)
+	}
+
+	return t
+}
+
+// Trial is a single call of an untyped experiment definition.
+type Trial = TypedTrial[interface{}]
+
+// TypedTrial is a single call of an experiment definition. It only has the
+// behaviors and Context for this call, and runs with the definition's
+// callbacks and options.
+type TypedTrial[T any] struct {
+	Context map[string]string
+
+	definition *TypedDefinition[T]
+	behaviors  []behavior[T]
+}
+
+func (t *TypedTrial[T]) Use(fn func() (T, error)) {
+	t.Behavior(controlBehavior, fn)
+}
+
+func (t *TypedTrial[T]) UseCtx(fn func(ctx context.Context) (T, error)) {
+	t.BehaviorCtx(controlBehavior, fn)
+}
+
+func (t *TypedTrial[T]) Try(fn func() (T, error)) {
+	t.Behavior(candidateBehavior, fn)
+}
+
+func (t *TypedTrial[T]) TryCtx(fn func(ctx context.Context) (T, error)) {
+	t.BehaviorCtx(candidateBehavior, fn)
+}
+
+func (t *TypedTrial[T]) Behavior(name string, fn func() (T, error)) {
+	t.behaviors = setBehavior(t.behaviors, behavior[T]{name: name, fn: fn})
+}
+
+func (t *TypedTrial[T]) BehaviorCtx(name string, fn func(ctx context.Context) (T, error)) {
+	t.behaviors = setBehavior(t.behaviors, behavior[T]{name: name, fnCtx: fn})
+}
+
+func (t *TypedTrial[T]) Run() (T, error) {
+	return t.RunBehaviorCtx(context.Background(), controlBehavior)
+}
+
+func (t *TypedTrial[T]) RunCtx(ctx context.Context) (T, error) {
+	return t.RunBehaviorCtx(ctx, controlBehavior)
+}
+
+func (t *TypedTrial[T]) RunBehavior(name string) (T, error) {
+	return t.RunBehaviorCtx(context.Background(), name)
+}
+
+func (t *TypedTrial[T]) RunBehaviorCtx(ctx context.Context, name string) (T, error) {
+	e := *t.definition.template
+	e.Context = t.Context
+	e.behaviors = t.behaviors
+	return e.RunBehaviorCtx(ctx, name)
+}
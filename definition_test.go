@@ -0,0 +1,131 @@
+package scientist
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestDefinition(t *testing.T) {
+	var mu sync.Mutex
+	published := make(map[string]int)
+	rollout := NewRollout(0)
+	rollout.Key = "user"
+	rollout.Allow = []string{"staff"}
+
+	d := Define("definition", func(e *Experiment) {
+		e.Context["app"] = "test"
+		e.Rollout = rollout
+		e.Concurrency = 2
+		e.Behavior("shared", func() (interface{}, error) {
+			return "shared", nil
+		})
+		e.Ignore(func(control, candidate interface{}) (bool, error) {
+			return candidate == "shared", nil
+		})
+		e.Publish(func(r Result) error {
+			mu.Lock()
+			published[r.Experiment.Context["user"]] += 1
+			mu.Unlock()
+
+			if r.Experiment.Context["app"] != "test" {
+				t.Errorf("Expected defined context to be copied: %v", r.Experiment.Context)
+			}
+
+			if r.IsMismatched() {
+				t.Errorf("Unexpected mismatch for %v", r.Experiment.Context)
+			}
+
+			if !r.IsIgnored() {
+				t.Errorf("Expected shared behavior to be ignored")
+			}
+
+			return nil
+		})
+	})
+
+	if d.Name() != "definition" {
+		t.Errorf("Unexpected name: %q", d.Name())
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			user := fmt.Sprintf("%d", i)
+			if i%2 == 0 {
+				user = "staff"
+			}
+
+			e := d.New()
+			e.Context["user"] = user
+			e.Use(func() (interface{}, error) {
+				return user, nil
+			})
+			e.Try(func() (interface{}, error) {
+				return user, nil
+			})
+
+			v, err := e.Run()
+			if v != user || err != nil {
+				t.Errorf("Unexpected control result for %q: %v, %v", user, v, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(published) != 1 || published["staff"] != 10 {
+		t.Errorf("Expected only staff results to be published: %v", published)
+	}
+}
+
+func TestDefinitionTyped(t *testing.T) {
+	d := DefineTyped[int]("definition.typed", func(e *TypedExperiment[int]) {
+		e.ErrorOnMismatches = true
+	})
+
+	e := d.New()
+	e.Use(func() (int, error) {
+		return 1, nil
+	})
+	e.Try(func() (int, error) {
+		return 2, nil
+	})
+
+	if _, err := e.Run(); err == nil {
+		t.Errorf("Expected mismatch error")
+	}
+
+	if again := d.New(); len(again.behaviors) != 0 || len(again.Context) != 0 {
+		t.Errorf("Expected a new experiment without behaviors or context")
+	}
+}
+
+var allocSink *Trial
+
+func TestDefinitionAllocations(t *testing.T) {
+	d := Define("definition.allocations", func(e *Experiment) {
+		e.Compare(func(control, candidate interface{}) (bool, error) {
+			return control == candidate, nil
+		})
+	})
+
+	// the trial, its Context map, and a slice of behaviors.
+	allocs := testing.AllocsPerRun(100, func() {
+		e := d.New()
+		e.Use(func() (interface{}, error) {
+			return nil, nil
+		})
+		e.Try(func() (interface{}, error) {
+			return nil, nil
+		})
+		e.Context["user"] = "1"
+		allocSink = e
+	})
+
+	if allocs > 4 {
+		t.Errorf("Unexpected allocations per call: %v", allocs)
+	}
+}
@@ -0,0 +1,434 @@
+package scientist
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Difference is a single difference between a control and candidate value,
+// at the given path, such as `.Users[3].Login`. The path of the top level
+// value is empty. Control or Candidate is Missing if the value only exists on
+// one side.
+type Difference struct {
+	Path      string
+	Control   interface{}
+	Candidate interface{}
+}
+
+func (d Difference) String() string {
+	path := d.Path
+	if path == "" {
+		path = "."
+	}
+	return fmt.Sprintf("%s: %s != %s", path, formatDiffValue(d.Control), formatDiffValue(d.Candidate))
+}
+
+// Diff is the list of differences between a control and candidate value.
+type Diff []Difference
+
+func (d Diff) String() string {
+	lines := make([]string, len(d))
+	for i, diff := range d {
+		lines[i] = diff.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+type missing struct{}
+
+func (missing) String() string {
+	return "<missing>"
+}
+
+// Missing marks a slice element or map key that only exists in one value.
+var Missing interface{} = missing{}
+
+// DiffValues walks structs, maps, slices, arrays, pointers, and interfaces to
+// find every path where the control and candidate values differ. Without any
+// options, it returns an empty Diff when reflect.DeepEqual considers the
+// values equal.
+func DiffValues(control, candidate interface{}, opts ...DiffOption) Diff {
+	return newDiffOptions(opts).diff(control, candidate)
+}
+
+// DiffOption changes how DiffValues and Equal compare values.
+type DiffOption func(*diffOptions)
+
+type diffOptions struct {
+	floatAbs      float64
+	floatRel      float64
+	timeTolerance time.Duration
+	unordered     bool
+	ignorePaths   map[string]bool
+	ignoreTags    map[string]string
+}
+
+// FloatTolerance treats floats as equal if they differ by no more than abs,
+// or by no more than rel times the larger value.
+func FloatTolerance(abs, rel float64) DiffOption {
+	return func(o *diffOptions) {
+		o.floatAbs = abs
+		o.floatRel = rel
+	}
+}
+
+// TimeTolerance treats time.Time values as equal if they are no more than d
+// apart, regardless of their location.
+func TimeTolerance(d time.Duration) DiffOption {
+	return func(o *diffOptions) {
+		o.timeTolerance = d
+	}
+}
+
+// UnorderedSlices compares slices and arrays as sets, ignoring the order of
+// their elements.
+func UnorderedSlices() DiffOption {
+	return func(o *diffOptions) {
+		o.unordered = true
+	}
+}
+
+// IgnorePaths skips the given paths, such as ".Users[*].UpdatedAt". "[*]"
+// matches any slice index or map key.
+func IgnorePaths(paths ...string) DiffOption {
+	return func(o *diffOptions) {
+		if o.ignorePaths == nil {
+			o.ignorePaths = make(map[string]bool, len(paths))
+		}
+		for _, path := range paths {
+			o.ignorePaths[path] = true
+		}
+	}
+}
+
+// IgnoreTag skips struct fields with the given tag, such as
+// `scientist:"ignore"` for IgnoreTag("scientist", "ignore").
+func IgnoreTag(key, value string) DiffOption {
+	return func(o *diffOptions) {
+		if o.ignoreTags == nil {
+			o.ignoreTags = make(map[string]string)
+		}
+		o.ignoreTags[key] = value
+	}
+}
+
+func newDiffOptions(opts []DiffOption) *diffOptions {
+	o := &diffOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func (o *diffOptions) diff(control, candidate interface{}) Diff {
+	d := newDiffer(o)
+	d.walk("", reflect.ValueOf(control), reflect.ValueOf(candidate))
+	return d.diff
+}
+
+func (o *diffOptions) ignoresPath(path string) bool {
+	if len(o.ignorePaths) == 0 {
+		return false
+	}
+	return o.ignorePaths[path] || o.ignorePaths[WildcardPath(path)]
+}
+
+func (o *diffOptions) ignoresField(f reflect.StructField) bool {
+	for key, value := range o.ignoreTags {
+		if tag, ok := f.Tag.Lookup(key); ok && tag == value {
+			return true
+		}
+	}
+	return false
+}
+
+// WildcardPath replaces every slice index and map key in a Difference path
+// with "[*]", so that ".Users[3].Login" becomes ".Users[*].Login".
+func WildcardPath(path string) string {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(path, '[')
+		if start < 0 {
+			b.WriteString(path)
+			return b.String()
+		}
+
+		end := strings.IndexByte(path[start:], ']')
+		if end < 0 {
+			b.WriteString(path)
+			return b.String()
+		}
+
+		b.WriteString(path[:start])
+		b.WriteString("[*]")
+		path = path[start+end+1:]
+	}
+}
+
+type visit struct {
+	control   uintptr
+	candidate uintptr
+	typ       reflect.Type
+}
+
+type differ struct {
+	diff    Diff
+	visited map[visit]bool
+	opts    *diffOptions
+}
+
+func newDiffer(o *diffOptions) *differ {
+	return &differ{visited: make(map[visit]bool), opts: o}
+}
+
+// equal checks if two values are equal without tracking their differences.
+func (d *differ) equal(path string, control, candidate reflect.Value) bool {
+	sub := newDiffer(d.opts)
+	sub.walk(path, control, candidate)
+	return len(sub.diff) == 0
+}
+
+func (d *differ) add(path string, control, candidate interface{}) {
+	d.diff = append(d.diff, Difference{Path: path, Control: control, Candidate: candidate})
+}
+
+func (d *differ) addValues(path string, control, candidate reflect.Value) {
+	d.add(path, diffValue(control), diffValue(candidate))
+}
+
+func (d *differ) walk(path string, control, candidate reflect.Value) {
+	if d.opts.ignoresPath(path) {
+		return
+	}
+
+	if !control.IsValid() || !candidate.IsValid() {
+		if control.IsValid() != candidate.IsValid() {
+			d.addValues(path, control, candidate)
+		}
+		return
+	}
+
+	if control.Type() != candidate.Type() {
+		d.addValues(path, control, candidate)
+		return
+	}
+
+	if control.Type() == timeType && control.CanInterface() {
+		if !d.equalTimes(control.Interface().(time.Time), candidate.Interface().(time.Time)) {
+			d.addValues(path, control, candidate)
+		}
+		return
+	}
+
+	switch control.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if control.IsNil() || candidate.IsNil() {
+			if control.IsNil() != candidate.IsNil() {
+				d.addValues(path, control, candidate)
+			}
+			return
+		}
+
+		if control.Pointer() == candidate.Pointer() && (control.Kind() != reflect.Slice || control.Len() == candidate.Len()) {
+			return
+		}
+
+		v := visit{control.Pointer(), candidate.Pointer(), control.Type()}
+		if d.visited[v] {
+			return
+		}
+		d.visited[v] = true
+	}
+
+	switch control.Kind() {
+	case reflect.Ptr:
+		d.walk(path, control.Elem(), candidate.Elem())
+
+	case reflect.Interface:
+		if control.IsNil() || candidate.IsNil() {
+			if control.IsNil() != candidate.IsNil() {
+				d.addValues(path, control, candidate)
+			}
+			return
+		}
+		d.walk(path, control.Elem(), candidate.Elem())
+
+	case reflect.Struct:
+		t := control.Type()
+		for i := 0; i < control.NumField(); i++ {
+			if d.opts.ignoresField(t.Field(i)) {
+				continue
+			}
+			d.walk(path+"."+t.Field(i).Name, control.Field(i), candidate.Field(i))
+		}
+
+	case reflect.Slice, reflect.Array:
+		if d.opts.unordered {
+			d.walkUnordered(path, control, candidate)
+			return
+		}
+
+		n := control.Len()
+		if candidate.Len() > n {
+			n = candidate.Len()
+		}
+
+		for i := 0; i < n; i++ {
+			elemPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= control.Len():
+				d.add(elemPath, Missing, diffValue(candidate.Index(i)))
+			case i >= candidate.Len():
+				d.add(elemPath, diffValue(control.Index(i)), Missing)
+			default:
+				d.walk(elemPath, control.Index(i), candidate.Index(i))
+			}
+		}
+
+	case reflect.Map:
+		for _, key := range mapKeys(control, candidate) {
+			keyPath := fmt.Sprintf("%s[%s]", path, formatDiffValue(diffValue(key)))
+			controlValue := control.MapIndex(key)
+			candidateValue := candidate.MapIndex(key)
+			switch {
+			case !controlValue.IsValid():
+				d.add(keyPath, Missing, diffValue(candidateValue))
+			case !candidateValue.IsValid():
+				d.add(keyPath, diffValue(controlValue), Missing)
+			default:
+				d.walk(keyPath, controlValue, candidateValue)
+			}
+		}
+
+	case reflect.Func:
+		if !control.IsNil() || !candidate.IsNil() {
+			d.addValues(path, control, candidate)
+		}
+
+	default:
+		if !d.equalLeaves(control, candidate) {
+			d.addValues(path, control, candidate)
+		}
+	}
+}
+
+// walkUnordered matches each control element with an equal candidate element
+// in any position. Unmatched elements are reported as missing on the other
+// side.
+func (d *differ) walkUnordered(path string, control, candidate reflect.Value) {
+	matched := make([]bool, candidate.Len())
+	for i := 0; i < control.Len(); i++ {
+		found := false
+		for j := 0; j < candidate.Len(); j++ {
+			if !matched[j] && d.equal(path, control.Index(i), candidate.Index(j)) {
+				matched[j] = true
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			d.add(fmt.Sprintf("%s[%d]", path, i), diffValue(control.Index(i)), Missing)
+		}
+	}
+
+	for j, ok := range matched {
+		if !ok {
+			d.add(fmt.Sprintf("%s[%d]", path, j), Missing, diffValue(candidate.Index(j)))
+		}
+	}
+}
+
+func (d *differ) equalTimes(control, candidate time.Time) bool {
+	if d.opts.timeTolerance <= 0 {
+		return reflect.DeepEqual(control, candidate)
+	}
+
+	delta := control.Sub(candidate)
+	if delta < 0 {
+		delta = -delta
+	}
+	return delta <= d.opts.timeTolerance
+}
+
+func (d *differ) equalFloats(control, candidate float64) bool {
+	if control == candidate {
+		return true
+	}
+
+	delta := math.Abs(control - candidate)
+	if delta <= d.opts.floatAbs {
+		return true
+	}
+
+	return delta <= d.opts.floatRel*math.Max(math.Abs(control), math.Abs(candidate))
+}
+
+// mapKeys returns the keys of both maps, sorted by their formatted value.
+func mapKeys(control, candidate reflect.Value) []reflect.Value {
+	keys := control.MapKeys()
+	for _, key := range candidate.MapKeys() {
+		if !control.MapIndex(key).IsValid() {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(diffValue(keys[i])) < fmt.Sprint(diffValue(keys[j]))
+	})
+	return keys
+}
+
+func (d *differ) equalLeaves(control, candidate reflect.Value) bool {
+	switch control.Kind() {
+	case reflect.Bool:
+		return control.Bool() == candidate.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return control.Int() == candidate.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return control.Uint() == candidate.Uint()
+	case reflect.Float32, reflect.Float64:
+		return d.equalFloats(control.Float(), candidate.Float())
+	case reflect.Complex64, reflect.Complex128:
+		return control.Complex() == candidate.Complex()
+	case reflect.String:
+		return control.String() == candidate.String()
+	case reflect.Chan, reflect.UnsafePointer:
+		return control.Pointer() == candidate.Pointer()
+	default:
+		return reflect.DeepEqual(diffValue(control), diffValue(candidate))
+	}
+}
+
+// diffValue returns the value inside v, even if it's from an unexported struct
+// field. Unexported values are returned as their formatted string.
+func diffValue(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+
+	if v.CanInterface() {
+		return v.Interface()
+	}
+
+	return fmt.Sprintf("%v", v)
+}
+
+func formatDiffValue(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "<nil>"
+	case missing:
+		return t.String()
+	case error:
+		return fmt.Sprintf("%q", t.Error())
+	default:
+		return fmt.Sprintf("%#v", v)
+	}
+}
@@ -0,0 +1,124 @@
+package scientist
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type diffUser struct {
+	Login string
+	Tags  map[string]int
+	login string
+}
+
+type diffUsers struct {
+	Users []*diffUser
+	Count int
+}
+
+func TestDiffValues(t *testing.T) {
+	control := diffUsers{
+		Users: []*diffUser{
+			{Login: "alice", Tags: map[string]int{"a": 1}},
+			{Login: "bob", Tags: map[string]int{"a": 1, "b": 2}, login: "bob"},
+		},
+		Count: 2,
+	}
+	candidate := diffUsers{
+		Users: []*diffUser{
+			{Login: "alice", Tags: map[string]int{"a": 1}},
+			{Login: "bobby", Tags: map[string]int{"a": 2, "c": 3}, login: "bobby"},
+			{Login: "carol"},
+		},
+		Count: 2,
+	}
+
+	diff := DiffValues(control, candidate)
+	actual := strings.Split(diff.String(), "\n")
+	expected := []string{
+		`.Users[1].Login: "bob" != "bobby"`,
+		`.Users[1].Tags["a"]: 1 != 2`,
+		`.Users[1].Tags["b"]: 2 != <missing>`,
+		`.Users[1].Tags["c"]: <missing> != 3`,
+		`.Users[1].login: "bob" != "bobby"`,
+		`.Users[2]: <missing> != &scientist.diffUser{Login:"carol", Tags:map[string]int(nil), login:""}`,
+	}
+
+	if len(actual) != len(expected) {
+		t.Fatalf("Expected %d differences, got:\n%s", len(expected), diff)
+	}
+
+	for i, line := range expected {
+		if actual[i] != line {
+			t.Errorf("Expected difference %d to be %s, got %s", i, line, actual[i])
+		}
+	}
+}
+
+func TestDiffValuesEqual(t *testing.T) {
+	values := []interface{}{
+		nil,
+		1,
+		"one",
+		[]int{1, 2},
+		map[string][]int{"a": {1}},
+		&diffUser{Login: "alice", login: "alice"},
+	}
+
+	for _, v := range values {
+		if diff := DiffValues(v, v); len(diff) > 0 {
+			t.Errorf("Expected no differences for %#v, got:\n%s", v, diff)
+		}
+	}
+}
+
+func TestDiffValuesTypes(t *testing.T) {
+	diff := DiffValues(1, "1")
+	if s := diff.String(); s != `.: 1 != "1"` {
+		t.Errorf("Unexpected diff: %s", s)
+	}
+
+	diff = DiffValues([]int{}, []int(nil))
+	if s := diff.String(); s != `.: []int{} != []int(nil)` {
+		t.Errorf("Unexpected diff: %s", s)
+	}
+}
+
+func TestMismatchDiff(t *testing.T) {
+	e := New("diff")
+	e.Use(func() (interface{}, error) {
+		return diffUser{Login: "bob"}, nil
+	})
+	e.Try(func() (interface{}, error) {
+		return diffUser{Login: "bobby"}, nil
+	})
+	e.Behavior("broken", func() (interface{}, error) {
+		return nil, errors.New("broken")
+	})
+	e.ErrorOnMismatches = true
+
+	_, err := e.Run()
+	mismatch, ok := err.(MismatchError)
+	if !ok {
+		t.Fatalf("Unexpected control error: %v", err)
+	}
+
+	diffs := mismatch.Result.Diffs()
+	if s := diffs["candidate"].String(); s != `.Login: "bob" != "bobby"` {
+		t.Errorf("Unexpected candidate diff: %s", s)
+	}
+
+	if s := diffs["broken"].String(); s != `(error): <nil> != "broken"` {
+		t.Errorf("Unexpected broken diff: %s", s)
+	}
+
+	expected := `[scientist] experiment "diff" observations mismatched
+  broken:
+    (error): <nil> != "broken"
+  candidate:
+    .Login: "bob" != "bobby"`
+	if msg := err.Error(); msg != expected {
+		t.Errorf("Unexpected error message:\n%s", msg)
+	}
+}
@@ -0,0 +1,111 @@
+package scientist
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestResultErrorUnwrap(t *testing.T) {
+	e := New("errors")
+	e.Use(func() (interface{}, error) {
+		return 1, nil
+	})
+	e.Try(func() (interface{}, error) {
+		return 2, nil
+	})
+	e.Compare(func(control, candidate interface{}) (bool, error) {
+		return false, io.EOF
+	})
+	e.Ignore(func(control, candidate interface{}) (bool, error) {
+		panic(io.ErrUnexpectedEOF)
+	})
+
+	r := Run(e, "control")
+	if len(r.Errors) != 2 {
+		t.Fatalf("Unexpected errors: %v", r.Errors)
+	}
+
+	compareErr, ignoreErr := r.Errors[0], r.Errors[1]
+	if compareErr.Operation != OperationCompare || compareErr.Operation.Panicked() || !errors.Is(compareErr, io.EOF) {
+		t.Errorf("Unexpected compare error: %q %v", compareErr.Operation, compareErr.Err)
+	}
+
+	if !ignoreErr.Operation.Panicked() || ignoreErr.Operation.Base() != OperationIgnore || !errors.Is(ignoreErr, io.ErrUnexpectedEOF) {
+		t.Errorf("Unexpected ignore error: %q %v", ignoreErr.Operation, ignoreErr.Err)
+	}
+
+	var perr PanicError
+	if !errors.As(ignoreErr, &perr) || perr.Value != io.ErrUnexpectedEOF {
+		t.Errorf("Expected ignore error to be a PanicError: %v", ignoreErr.Err)
+	}
+}
+
+func TestMismatchErrorInspection(t *testing.T) {
+	e := NewTyped[int]("errors.mismatch")
+	e.ErrorOnMismatches = true
+	e.Use(func() (int, error) {
+		return 1, nil
+	})
+	e.Try(func() (int, error) {
+		return 2, nil
+	})
+	e.Behavior("correct", func() (int, error) {
+		return 1, nil
+	})
+	e.Behavior("three", func() (int, error) {
+		return 3, nil
+	})
+
+	_, err := e.Run()
+	if !errors.Is(err, ErrMismatched) {
+		t.Fatalf("Expected a mismatch error: %v", err)
+	}
+
+	var mismatch TypedMismatchError[int]
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Unexpected error type: %T", err)
+	}
+
+	if names := mismatch.Candidates(); !reflect.DeepEqual(names, []string{"candidate", "three"}) {
+		t.Errorf("Unexpected mismatched candidates: %v", names)
+	}
+
+	if diffs := mismatch.Diffs(); len(diffs) != 2 || diffs["three"].String() != ".: 1 != 3" {
+		t.Errorf("Unexpected diffs: %v", diffs)
+	}
+}
+
+func TestBehaviorNotFoundErrors(t *testing.T) {
+	e := New("errors.not_found")
+	e.Use(func() (interface{}, error) {
+		return 1, nil
+	})
+
+	_, err := e.RunBehavior("missing")
+	if !errors.Is(err, ErrBehaviorNotFound) || errors.Is(err, ErrExperimentDisabled) {
+		t.Errorf("Unexpected error for a missing behavior: %v", err)
+	}
+
+	e.Behavior("api", func() (interface{}, error) {
+		return 1, nil
+	})
+	e.RunIf(func() (bool, error) {
+		return false, nil
+	})
+
+	_, err = e.RunBehavior("missing")
+	if !errors.Is(err, ErrBehaviorNotFound) || !errors.Is(err, ErrExperimentDisabled) {
+		t.Errorf("Unexpected error for a disabled experiment: %v", err)
+	}
+
+	e.RunIf(func() (bool, error) {
+		return true, nil
+	})
+
+	r := Run(e, "missing")
+	if !errors.Is(r.Control.Err, ErrBehaviorNotFound) {
+		t.Errorf("Unexpected control error: %v", r.Control.Err)
+	}
+}
@@ -1,131 +1,353 @@
 package scientist
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"os"
 	"reflect"
+	"sync"
+	"time"
 )
 
 var ErrorOnMismatches bool
 
+// New creates an untyped experiment whose behaviors return interface{} values,
+// with any Defaults registered for its name.
 func New(name string) *Experiment {
-	return &Experiment{
+	return NewTyped[interface{}](name)
+}
+
+// NewTyped creates an experiment whose behaviors all return values of type T.
+func NewTyped[T any](name string) *TypedExperiment[T] {
+	e := &TypedExperiment[T]{
 		Name:              name,
 		Context:           make(map[string]string),
 		ErrorOnMismatches: ErrorOnMismatches,
-		behaviors:         make(map[string]behaviorFunc),
-		comparator:        defaultComparator,
+		comparator:        defaultComparator[T],
+		errComparator:     ErrorMessagesEqual,
 		runcheck:          defaultRunCheck,
-		publisher:         defaultPublisher,
+		publisher:         defaultPublisher[T],
 		errorReporter:     defaultErrorReporter,
 		beforeRun:         defaultBeforeRun,
-		cleaner:           defaultCleaner,
+		cleaner:           defaultCleaner[T],
+		shuffle:           rand.Shuffle,
+	}
+
+	registeredStats(name)
+	applyDefaults(e)
+	return e
+}
+
+type behaviorFunc[T any] func(ctx context.Context) (value T, err error)
+
+// behavior is a named behavior. Behaviors set with Behavior keep their
+// function as is, instead of wrapping it in a behaviorFunc.
+type behavior[T any] struct {
+	name  string
+	fn    func() (T, error)
+	fnCtx behaviorFunc[T]
+}
+
+func (b behavior[T]) call(ctx context.Context) (T, error) {
+	if b.fnCtx != nil {
+		return b.fnCtx(ctx)
 	}
+	return b.fn()
 }
 
-type behaviorFunc func() (value interface{}, err error)
+// Experiment is an experiment whose behaviors return interface{} values.
+type Experiment = TypedExperiment[interface{}]
 
-type Experiment struct {
+type TypedExperiment[T any] struct {
 	Name              string
 	Context           map[string]string
 	ErrorOnMismatches bool
-	behaviors         map[string]behaviorFunc
-	ignores           []func(control, candidate interface{}) (bool, error)
-	comparator        func(control, candidate interface{}) (bool, error)
-	runcheck          func() (bool, error)
-	publisher         func(Result) error
-	errorReporter     func(...ResultError)
-	beforeRun         func() error
-	cleaner           func(interface{}) (interface{}, error)
+
+	// RecoverControlPanics returns a panic in the control behavior as a
+	// PanicError. By default, the panic is raised again once the experiment
+	// has finished.
+	RecoverControlPanics bool
+
+	// Concurrency is the number of behaviors that an experiment may run at
+	// once. Behaviors run one after another by default.
+	Concurrency int
+
+	// Async runs candidates in the background on the given pool, returning
+	// the control value as soon as the control behavior finishes.
+	Async *AsyncPool
+
+	// CandidateTimeout is how long a candidate may run before its context is
+	// canceled and it is recorded as timed out.
+	CandidateTimeout time.Duration
+
+	// Rollout runs the experiment for a percentage of calls, using the
+	// experiment's Context for sticky bucketing. It's checked after RunIf.
+	Rollout *Rollout
+
+	// Guard disables the experiment if its candidates mismatch or error too
+	// often.
+	Guard *Guard
+
+	// CleanBeforeCompare passes cleaned values to the Compare and Ignore
+	// callbacks, instead of the values that behaviors return. The Clean
+	// callback must return a T.
+	CleanBeforeCompare bool
+
+	// MeasureResources records the allocations and CPU time of each behavior
+	// in its observation's Resources. It's only accurate when behaviors run
+	// one at a time, and slows them down.
+	MeasureResources bool
+
+	behaviors     []behavior[T]
+	ignores       []func(control, candidate *TypedObservation[T]) (bool, error)
+	comparator    func(control, candidate T) (bool, error)
+	errComparator func(control, candidate error) (bool, error)
+	runcheck      func(name string, context map[string]string) (bool, error)
+	publisher     func(TypedResult[T]) error
+	errorReporter func(...ResultError)
+	beforeRun     func() error
+	cleaner       func(T) (interface{}, error)
+	shuffle       func(n int, swap func(i, j int))
+	order         []string
 }
 
-func (e *Experiment) Use(fn func() (interface{}, error)) {
+func (e *TypedExperiment[T]) Use(fn func() (T, error)) {
 	e.Behavior(controlBehavior, fn)
 }
 
-func (e *Experiment) Try(fn func() (interface{}, error)) {
+func (e *TypedExperiment[T]) UseCtx(fn func(ctx context.Context) (T, error)) {
+	e.BehaviorCtx(controlBehavior, fn)
+}
+
+func (e *TypedExperiment[T]) Try(fn func() (T, error)) {
 	e.Behavior(candidateBehavior, fn)
 }
 
-func (e *Experiment) Behavior(name string, fn func() (interface{}, error)) {
-	e.behaviors[name] = fn
+func (e *TypedExperiment[T]) TryCtx(fn func(ctx context.Context) (T, error)) {
+	e.BehaviorCtx(candidateBehavior, fn)
+}
+
+func (e *TypedExperiment[T]) Behavior(name string, fn func() (T, error)) {
+	e.setBehavior(behavior[T]{name: name, fn: fn})
+}
+
+func (e *TypedExperiment[T]) BehaviorCtx(name string, fn func(ctx context.Context) (T, error)) {
+	e.setBehavior(behavior[T]{name: name, fnCtx: fn})
+}
+
+func (e *TypedExperiment[T]) behavior(name string) (behavior[T], bool) {
+	for _, b := range e.behaviors {
+		if b.name == name {
+			return b, true
+		}
+	}
+	return behavior[T]{}, false
+}
+
+func (e *TypedExperiment[T]) setBehavior(b behavior[T]) {
+	e.behaviors = setBehavior(e.behaviors, b)
+}
+
+func setBehavior[T any](behaviors []behavior[T], b behavior[T]) []behavior[T] {
+	for i := range behaviors {
+		if behaviors[i].name == b.name {
+			behaviors[i] = b
+			return behaviors
+		}
+	}
+
+	if behaviors == nil {
+		// room for a control and candidate.
+		behaviors = make([]behavior[T], 0, 2)
+	}
+	return append(behaviors, b)
 }
 
-func (e *Experiment) Compare(fn func(control, candidate interface{}) (bool, error)) {
+func (e *TypedExperiment[T]) Compare(fn func(control, candidate T) (bool, error)) {
 	e.comparator = fn
 }
 
-func (e *Experiment) Clean(fn func(v interface{}) (interface{}, error)) {
+func (e *TypedExperiment[T]) Clean(fn func(v T) (interface{}, error)) {
 	e.cleaner = fn
 }
 
-func (e *Experiment) Ignore(fn func(control, candidate interface{}) (bool, error)) {
+// CompareErrors sets the callback that compares the errors when both the
+// control and a candidate return one. By default, errors match if their
+// messages are equal.
+func (e *TypedExperiment[T]) CompareErrors(fn func(control, candidate error) (bool, error)) {
+	e.errComparator = fn
+}
+
+func (e *TypedExperiment[T]) Ignore(fn func(control, candidate T) (bool, error)) {
+	e.IgnoreObservations(func(control, candidate *TypedObservation[T]) (bool, error) {
+		controlValue, _ := control.comparedValue()
+		candidateValue, _ := candidate.comparedValue()
+		return fn(controlValue, candidateValue)
+	})
+}
+
+// IgnoreObservations is like Ignore, but the callback gets the control and
+// candidate observations, so it can check their errors:
+//
+//	experiment.IgnoreObservations(func(control, candidate *scientist.Observation) (bool, error) {
+//	  return errors.Is(candidate.Err, ErrNotMigrated), nil
+//	})
+func (e *TypedExperiment[T]) IgnoreObservations(fn func(control, candidate *TypedObservation[T]) (bool, error)) {
 	e.ignores = append(e.ignores, fn)
 }
 
-func (e *Experiment) RunIf(fn func() (bool, error)) {
+// RandSource sets the source used to randomize the order that behaviors run
+// in. Use a fixed seed for a predictable order in tests.
+func (e *TypedExperiment[T]) RandSource(src rand.Source) {
+	// a Definition shares this between goroutines.
+	var mu sync.Mutex
+	r := rand.New(src)
+	e.shuffle = func(n int, swap func(i, j int)) {
+		mu.Lock()
+		r.Shuffle(n, swap)
+		mu.Unlock()
+	}
+}
+
+// Order runs behaviors in the given order, instead of a random one. Any
+// behaviors that aren't listed, including the control, run afterwards in name
+// order.
+func (e *TypedExperiment[T]) Order(names ...string) {
+	e.order = names
+}
+
+func (e *TypedExperiment[T]) RunIf(fn func() (bool, error)) {
+	e.runcheck = func(string, map[string]string) (bool, error) {
+		return fn()
+	}
+}
+
+// RunIfContext is like RunIf, but the callback gets the experiment's name and
+// Context when the experiment runs, such as Rollout.RunIf:
+//
+//	experiment.RunIfContext(rollout.RunIf)
+func (e *TypedExperiment[T]) RunIfContext(fn func(name string, context map[string]string) (bool, error)) {
 	e.runcheck = fn
 }
 
-func (e *Experiment) BeforeRun(fn func() error) {
+func (e *TypedExperiment[T]) BeforeRun(fn func() error) {
 	e.beforeRun = fn
 }
 
-func (e *Experiment) Publish(fn func(Result) error) {
+func (e *TypedExperiment[T]) Publish(fn func(TypedResult[T]) error) {
 	e.publisher = fn
 }
 
-func (e *Experiment) ReportErrors(fn func(...ResultError)) {
+func (e *TypedExperiment[T]) ReportErrors(fn func(...ResultError)) {
 	e.errorReporter = fn
 }
 
-func (e *Experiment) Run() (interface{}, error) {
-	return e.RunBehavior(controlBehavior)
+func (e *TypedExperiment[T]) Run() (T, error) {
+	return e.RunBehaviorCtx(context.Background(), controlBehavior)
+}
+
+func (e *TypedExperiment[T]) RunCtx(ctx context.Context) (T, error) {
+	return e.RunBehaviorCtx(ctx, controlBehavior)
 }
 
-func (e *Experiment) RunBehavior(name string) (interface{}, error) {
-	enabled, err := e.runcheck()
+func (e *TypedExperiment[T]) RunBehavior(name string) (T, error) {
+	return e.RunBehaviorCtx(context.Background(), name)
+}
+
+func (e *TypedExperiment[T]) RunBehaviorCtx(ctx context.Context, name string) (T, error) {
+	var zero T
+	enabled, err := recoverCall(func() (bool, error) {
+		return e.runcheck(e.Name, e.Context)
+	})
 	if err != nil {
-		enabled = true
-		e.errorReporter(e.resultErr("run_if", err))
-		return nil, err
+		e.errorReporter(e.resultErr(OperationRunIf, err))
+
+		// a panicking RunIf is a bug in the experiment, so only the control runs.
+		if _, ok := err.(PanicError); !ok {
+			return zero, err
+		}
+		enabled = false
+	}
+
+	if enabled && e.Rollout != nil {
+		enabled = e.Rollout.Enabled(e.Name, e.Context)
+	}
+
+	if enabled && e.Guard != nil {
+		enabled, err = e.Guard.allow(e.Name)
+		if err != nil {
+			e.errorReporter(e.resultErr(OperationGuard, err))
+		}
+	}
+
+	trackRun(e.Name, enabled && len(e.behaviors) > 1)
+
+	if enabled && len(e.behaviors) > 1 && e.Async != nil {
+		control := runAsync(ctx, e, name)
+		if perr, ok := control.Err.(PanicError); ok && !e.RecoverControlPanics {
+			panic(perr.Value)
+		}
+
+		return control.Value, control.Err
 	}
 
 	if enabled && len(e.behaviors) > 1 {
-		r := Run(e, name)
+		r := RunCtx(ctx, e, name)
+
+		if perr, ok := r.Control.Err.(PanicError); ok && !e.RecoverControlPanics {
+			panic(perr.Value)
+		}
 
 		if r.Control.Err == nil && e.ErrorOnMismatches && r.IsMismatched() {
-			return nil, MismatchError{r}
+			return zero, TypedMismatchError[T]{r}
 		}
 
 		return r.Control.Value, r.Control.Err
 	}
 
-	behavior, ok := e.behaviors[name]
+	behavior, ok := e.behavior(name)
+	if !ok && !enabled {
+		return zero, fmt.Errorf("%w: %w", ErrExperimentDisabled, behaviorNotFound(e, name))
+	}
+
 	if !ok {
-		return nil, behaviorNotFound(e, name)
+		return zero, behaviorNotFound(e, name)
+	}
+
+	if e.RecoverControlPanics {
+		return recoverCall(func() (T, error) {
+			return behavior.call(ctx)
+		})
 	}
 
-	return behavior()
+	return behavior.call(ctx)
 }
 
-func (e *Experiment) resultErr(name string, err error) ResultError {
-	return ResultError{name, e.Name, err}
+// resultErr tags errors from panicking callbacks with a "_panic" suffix, such
+// as "compare_panic".
+func (e *TypedExperiment[T]) resultErr(op Operation, err error) ResultError {
+	var perr PanicError
+	if errors.As(err, &perr) {
+		op += panicSuffix
+	}
+	return ResultError{op, e.Name, err}
 }
 
-func defaultComparator(candidate, control interface{}) (bool, error) {
+func defaultComparator[T any](candidate, control T) (bool, error) {
 	return reflect.DeepEqual(candidate, control), nil
 }
 
-func defaultRunCheck() (bool, error) {
+func defaultRunCheck(string, map[string]string) (bool, error) {
 	return true, nil
 }
 
-func defaultCleaner(v interface{}) (interface{}, error) {
+func defaultCleaner[T any](v T) (interface{}, error) {
 	return v, nil
 }
 
-func defaultPublisher(r Result) error {
+func defaultPublisher[T any](r TypedResult[T]) error {
 	return nil
 }
 
@@ -1,9 +1,11 @@
 package scientist
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 )
 
 func TestExperimentMatch(t *testing.T) {
@@ -393,3 +395,367 @@ func TestExperimentSkipCompareSameErrors(t *testing.T) {
 		t.Errorf("results never published")
 	}
 }
+
+func TestExperimentCompareErrors(t *testing.T) {
+	errNotFound := errors.New("not found")
+	e := New("errors")
+	e.Use(func() (interface{}, error) {
+		return nil, fmt.Errorf("user 1: %w", errNotFound)
+	})
+	e.Try(func() (interface{}, error) {
+		return nil, fmt.Errorf("no user 1: %w", errNotFound)
+	})
+
+	if r := Run(e, "control"); !r.IsMismatched() {
+		t.Errorf("Expected different error messages to mismatch")
+	}
+
+	e.CompareErrors(ErrorsIs(errNotFound))
+	if r := Run(e, "control"); !r.IsMatched() {
+		t.Errorf("Expected wrapped errors to match")
+	}
+
+	e.CompareErrors(func(control, candidate error) (bool, error) {
+		panic("(compare errors)")
+	})
+	if r := Run(e, "control"); !r.IsMismatched() || len(r.Errors) != 1 || r.Errors[0].Operation != "compare_panic" {
+		t.Errorf("Expected error comparator panic to be reported: %v", r.Errors)
+	}
+}
+
+func TestExperimentIgnoreObservations(t *testing.T) {
+	errNotMigrated := errors.New("not migrated")
+	e := New("errors")
+	e.Use(func() (interface{}, error) {
+		return 1, nil
+	})
+	e.Try(func() (interface{}, error) {
+		return nil, errNotMigrated
+	})
+	e.Behavior("broken", func() (interface{}, error) {
+		return nil, errors.New("broken")
+	})
+	e.IgnoreObservations(func(control, candidate *Observation) (bool, error) {
+		if control.Value != 1 || control.Err != nil {
+			t.Errorf("Unexpected control observation: %v, %v", control.Value, control.Err)
+		}
+		return errors.Is(candidate.Err, errNotMigrated), nil
+	})
+
+	r := Run(e, "control")
+	assertObservationNames(t, "ignored", r.Ignored, []string{"candidate"})
+	assertObservationNames(t, "mismatched", r.Mismatched, []string{"broken"})
+}
+
+func TestTypedExperimentMatch(t *testing.T) {
+	e := NewTyped[bool]("typed")
+	e.Use(func() (bool, error) {
+		return true, nil
+	})
+	e.Try(func() (bool, error) {
+		return true, nil
+	})
+
+	published := false
+	e.Publish(func(r TypedResult[bool]) error {
+		published = true
+
+		if !r.IsMatched() {
+			t.Errorf("not matched")
+		}
+
+		if !r.Control.Value || !r.Candidates[0].Value {
+			t.Errorf("Unexpected observation values: %v, %v", r.Control.Value, r.Candidates[0].Value)
+		}
+
+		return nil
+	})
+
+	v, err := e.Run()
+	if !v {
+		t.Errorf("Unexpected control value: %v", v)
+	}
+
+	if err != nil {
+		t.Errorf("Unexpected control error: %v", err)
+	}
+
+	if !published {
+		t.Errorf("expected Publish callback to run")
+	}
+}
+
+func TestTypedExperimentMismatchWithReturn(t *testing.T) {
+	e := NewTyped[string]("typed")
+	e.Use(func() (string, error) {
+		return "bob", nil
+	})
+	e.Try(func() (string, error) {
+		return "BOB", nil
+	})
+	e.Clean(func(v string) (interface{}, error) {
+		return len(v), nil
+	})
+	e.Compare(func(control, candidate string) (bool, error) {
+		return control == candidate, nil
+	})
+
+	e.ErrorOnMismatches = true
+
+	v, err := e.Run()
+	if v != "" {
+		t.Errorf("Unexpected control value: %q", v)
+	}
+
+	mismatch, ok := err.(TypedMismatchError[string])
+	if !ok {
+		t.Fatalf("Unexpected control error: %v", err)
+	}
+
+	cleaned, err := mismatch.Result.Mismatched[0].CleanedValue()
+	if err != nil {
+		t.Errorf("Unexpected cleaning error: %v", err)
+	}
+
+	if cleaned != 3 {
+		t.Errorf("bad cleaned value: %v", cleaned)
+	}
+}
+
+func TestExperimentCandidatePanic(t *testing.T) {
+	e := New("panic")
+	e.Use(func() (interface{}, error) {
+		return 1, nil
+	})
+	e.Try(func() (interface{}, error) {
+		panic("try")
+	})
+
+	published := false
+	e.Publish(func(r Result) error {
+		published = true
+
+		if !r.IsMismatched() {
+			t.Errorf("Expected mismatch")
+		}
+
+		perr, ok := r.Candidates[0].Err.(PanicError)
+		if !ok {
+			t.Fatalf("Unexpected candidate error: %v", r.Candidates[0].Err)
+		}
+
+		if perr.Value != "try" {
+			t.Errorf("Unexpected panic value: %v", perr.Value)
+		}
+
+		if len(perr.Stack) == 0 {
+			t.Errorf("Expected a stack trace")
+		}
+
+		return nil
+	})
+
+	v, err := e.Run()
+	if v != 1 {
+		t.Errorf("Unexpected control value: %d", v)
+	}
+
+	if err != nil {
+		t.Errorf("Unexpected control error: %v", err)
+	}
+
+	if !published {
+		t.Errorf("expected Publish callback to run")
+	}
+}
+
+func TestExperimentControlPanic(t *testing.T) {
+	e := New("panic")
+	e.Use(func() (interface{}, error) {
+		panic("use")
+	})
+	e.Try(func() (interface{}, error) {
+		return 1, nil
+	})
+
+	published := false
+	e.Publish(func(r Result) error {
+		published = true
+		return nil
+	})
+
+	defer func() {
+		if p := recover(); p != "use" {
+			t.Errorf("Unexpected panic: %v", p)
+		}
+
+		if !published {
+			t.Errorf("expected Publish callback to run before panicking")
+		}
+	}()
+
+	e.Run()
+	t.Errorf("expected control panic")
+}
+
+func TestExperimentRecoverControlPanics(t *testing.T) {
+	e := New("panic")
+	e.RecoverControlPanics = true
+	e.Use(func() (interface{}, error) {
+		panic("use")
+	})
+	e.Try(func() (interface{}, error) {
+		return 1, nil
+	})
+
+	v, err := e.Run()
+	if v != nil {
+		t.Errorf("Unexpected control value: %v", v)
+	}
+
+	if perr, ok := err.(PanicError); !ok || perr.Value != "use" {
+		t.Errorf("Unexpected control error: %v", err)
+	}
+
+	e.RunIf(func() (bool, error) {
+		return false, nil
+	})
+
+	v, err = e.Run()
+	if v != nil {
+		t.Errorf("Unexpected control value: %v", v)
+	}
+
+	if perr, ok := err.(PanicError); !ok || perr.Value != "use" {
+		t.Errorf("Unexpected disabled control error: %v", err)
+	}
+}
+
+func TestExperimentRunIfPanic(t *testing.T) {
+	reported, ran := false, false
+	e := New("panic")
+	e.Use(func() (interface{}, error) {
+		return 1, nil
+	})
+	e.Try(func() (interface{}, error) {
+		ran = true
+		return 1, nil
+	})
+	e.RunIf(func() (bool, error) {
+		panic("run_if")
+	})
+	e.ReportErrors(func(errors ...ResultError) {
+		for _, err := range errors {
+			if err.Operation != "run_if_panic" {
+				t.Errorf("Bad operation: %q", err.Operation)
+			}
+			reported = true
+		}
+	})
+
+	value, err := e.Run()
+	if value != 1 || err != nil {
+		t.Errorf("Expected the control to run: %v, %v", value, err)
+	}
+
+	if ran {
+		t.Errorf("Expected the candidate not to run")
+	}
+
+	if !reported {
+		t.Errorf("result errors never reported!")
+	}
+}
+
+type ctxKey struct{}
+
+func TestExperimentRunCtx(t *testing.T) {
+	e := New("ctx")
+	e.UseCtx(func(ctx context.Context) (interface{}, error) {
+		return ctx.Value(ctxKey{}), nil
+	})
+	e.TryCtx(func(ctx context.Context) (interface{}, error) {
+		return ctx.Value(ctxKey{}), nil
+	})
+
+	published := false
+	e.Publish(func(r Result) error {
+		published = true
+
+		if !r.IsMatched() {
+			t.Errorf("not matched")
+		}
+
+		return nil
+	})
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+	v, err := e.RunCtx(ctx)
+	if v != "value" {
+		t.Errorf("Unexpected control value: %v", v)
+	}
+
+	if err != nil {
+		t.Errorf("Unexpected control error: %v", err)
+	}
+
+	if !published {
+		t.Errorf("expected Publish callback to run")
+	}
+}
+
+func TestExperimentCandidateTimeout(t *testing.T) {
+	canceled := make(chan error, 1)
+
+	e := New("timeout")
+	e.CandidateTimeout = 10 * time.Millisecond
+	e.Use(func() (interface{}, error) {
+		return 1, nil
+	})
+	e.TryCtx(func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		canceled <- ctx.Err()
+		return 1, nil
+	})
+
+	published := false
+	e.Publish(func(r Result) error {
+		published = true
+
+		c := r.Candidates[0]
+		if !c.TimedOut {
+			t.Errorf("Expected candidate to time out")
+		}
+
+		if c.Err != ErrCandidateTimeout {
+			t.Errorf("Unexpected candidate error: %v", c.Err)
+		}
+
+		if r.Control.TimedOut {
+			t.Errorf("Did not expect control to time out")
+		}
+
+		if !r.IsMismatched() {
+			t.Errorf("Expected mismatch")
+		}
+
+		return nil
+	})
+
+	v, err := e.Run()
+	if v != 1 {
+		t.Errorf("Unexpected control value: %d", v)
+	}
+
+	if err != nil {
+		t.Errorf("Unexpected control error: %v", err)
+	}
+
+	if !published {
+		t.Errorf("expected Publish callback to run")
+	}
+
+	if err := <-canceled; err != context.DeadlineExceeded {
+		t.Errorf("Expected candidate context to be canceled, got: %v", err)
+	}
+}
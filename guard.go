@@ -0,0 +1,264 @@
+package scientist
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Guard disables experiments whose candidates mismatch, error, or run slowly
+// too often. Rates are tracked separately for each experiment name over its
+// most recent results, so one Guard can be shared by many experiments. Set it
+// as an experiment's Guard:
+//
+//	var guard = &scientist.Guard{Window: 100, MaxMismatchRate: 0.1}
+//
+//	experiment.Guard = guard
+type Guard struct {
+	// Window is the number of recent results to track for each experiment.
+	// Defaults to 100.
+	Window int
+
+	// MinSamples is the number of results needed before the guard can trip.
+	// Defaults to Window.
+	MinSamples int
+
+	// MaxMismatchRate, MaxErrorRate, and MaxSlowRate are the rates from 0 to 1
+	// that trip the guard when exceeded. A zero rate is not checked.
+	MaxMismatchRate float64
+	MaxErrorRate    float64
+	MaxSlowRate     float64
+
+	// SlowThreshold is the candidate runtime that counts towards
+	// MaxSlowRate.
+	SlowThreshold time.Duration
+
+	// Cooldown is how long a tripped experiment stays disabled before it is
+	// re-enabled. A tripped experiment stays disabled until Reset if this is
+	// zero.
+	Cooldown time.Duration
+
+	// Notify is called when an experiment trips or resets. Trips are also
+	// reported to the experiment's ReportErrors callback as "guard" errors, and
+	// panics in Notify as "guard_panic" errors.
+	Notify func(GuardEvent)
+
+	mu     sync.Mutex
+	states map[string]*guardState
+	now    func() time.Time
+}
+
+// GuardEvent describes an experiment tripping or resetting its Guard.
+type GuardEvent struct {
+	Experiment string
+	Tripped    bool
+	Reason     string
+	Time       time.Time
+}
+
+func (e GuardEvent) Error() string {
+	if e.Tripped {
+		return fmt.Sprintf("[scientist] experiment %q disabled: %s", e.Experiment, e.Reason)
+	}
+	return fmt.Sprintf("[scientist] experiment %q re-enabled: %s", e.Experiment, e.Reason)
+}
+
+type guardSample struct {
+	mismatched bool
+	errored    bool
+	slow       bool
+}
+
+type guardState struct {
+	samples   []guardSample
+	next      int
+	tripped   bool
+	trippedAt time.Time
+}
+
+// Tripped checks if the named experiment is disabled.
+func (g *Guard) Tripped(name string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	state := g.states[name]
+	return state != nil && state.tripped
+}
+
+// Reset re-enables the named experiment, and clears its tracked results.
+func (g *Guard) Reset(name string) {
+	g.mu.Lock()
+	event := g.reset(name, "reset")
+	g.mu.Unlock()
+
+	if event != nil {
+		// there's no experiment to report a panic to.
+		_ = g.notify(*event)
+	}
+}
+
+// allow checks if the named experiment may run. It resets the experiment if
+// its cooldown has passed. The error is from the Notify callback.
+func (g *Guard) allow(name string) (bool, error) {
+	g.mu.Lock()
+	state := g.states[name]
+	if state == nil || !state.tripped {
+		g.mu.Unlock()
+		return true, nil
+	}
+
+	if g.Cooldown <= 0 || g.clock().Sub(state.trippedAt) < g.Cooldown {
+		g.mu.Unlock()
+		return false, nil
+	}
+
+	event := g.reset(name, "cooldown passed")
+	g.mu.Unlock()
+
+	if event != nil {
+		return true, g.notify(*event)
+	}
+	return true, nil
+}
+
+// record tracks a result, and returns an event if it trips the guard. The
+// error is from the Notify callback.
+func (g *Guard) record(name string, sample guardSample) (*GuardEvent, error) {
+	g.mu.Lock()
+	state := g.state(name)
+	if state.tripped {
+		g.mu.Unlock()
+		return nil, nil
+	}
+
+	if len(state.samples) < g.window() {
+		state.samples = append(state.samples, sample)
+	} else {
+		state.samples[state.next] = sample
+		state.next = (state.next + 1) % len(state.samples)
+	}
+
+	reason := g.check(state)
+	if reason == "" {
+		g.mu.Unlock()
+		return nil, nil
+	}
+
+	state.tripped = true
+	state.trippedAt = g.clock()
+	event := GuardEvent{Experiment: name, Tripped: true, Reason: reason, Time: state.trippedAt}
+	g.mu.Unlock()
+
+	return &event, g.notify(event)
+}
+
+func (g *Guard) check(state *guardState) string {
+	minSamples := g.MinSamples
+	if minSamples <= 0 {
+		minSamples = g.window()
+	}
+
+	if len(state.samples) < minSamples {
+		return ""
+	}
+
+	var mismatched, errored, slow int
+	for _, s := range state.samples {
+		if s.mismatched {
+			mismatched += 1
+		}
+		if s.errored {
+			errored += 1
+		}
+		if s.slow {
+			slow += 1
+		}
+	}
+
+	total := float64(len(state.samples))
+	checks := []struct {
+		name  string
+		count int
+		max   float64
+	}{
+		{"mismatch", mismatched, g.MaxMismatchRate},
+		{"error", errored, g.MaxErrorRate},
+		{"slow", slow, g.MaxSlowRate},
+	}
+
+	for _, c := range checks {
+		rate := float64(c.count) / total
+		if c.max > 0 && rate > c.max {
+			return fmt.Sprintf("%s rate %.2f exceeded %.2f", c.name, rate, c.max)
+		}
+	}
+
+	return ""
+}
+
+func (g *Guard) reset(name, reason string) *GuardEvent {
+	state := g.states[name]
+	if state == nil {
+		return nil
+	}
+
+	delete(g.states, name)
+	if !state.tripped {
+		return nil
+	}
+
+	return &GuardEvent{Experiment: name, Reason: reason, Time: g.clock()}
+}
+
+func (g *Guard) state(name string) *guardState {
+	if g.states == nil {
+		g.states = make(map[string]*guardState)
+	}
+
+	state := g.states[name]
+	if state == nil {
+		state = &guardState{}
+		g.states[name] = state
+	}
+	return state
+}
+
+func (g *Guard) notify(event GuardEvent) error {
+	if g.Notify == nil {
+		return nil
+	}
+
+	return recoverErr(func() error {
+		g.Notify(event)
+		return nil
+	})
+}
+
+func (g *Guard) window() int {
+	if g.Window > 0 {
+		return g.Window
+	}
+	return 100
+}
+
+func (g *Guard) clock() time.Time {
+	if g.now != nil {
+		return g.now()
+	}
+	return time.Now()
+}
+
+func newGuardSample[T any](g *Guard, r TypedResult[T]) guardSample {
+	s := guardSample{mismatched: r.IsMismatched()}
+	for _, c := range r.Candidates {
+		// errors that the control also returned, like a missing record, aren't
+		// the candidate's fault.
+		if c.Err != nil && (r.Control.Err == nil || candidateStatus(r, c) == StatusMismatched) {
+			s.errored = true
+		}
+
+		if g.SlowThreshold > 0 && c.Runtime > g.SlowThreshold {
+			s.slow = true
+		}
+	}
+	return s
+}
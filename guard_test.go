@@ -0,0 +1,184 @@
+package scientist
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func guardedExperiment(g *Guard, candidate func() (interface{}, error), ran *int, reported *[]ResultError) *Experiment {
+	e := New("guarded")
+	e.Guard = g
+	e.Use(func() (interface{}, error) {
+		return 1, nil
+	})
+	e.Try(func() (interface{}, error) {
+		*ran += 1
+		return candidate()
+	})
+	e.ReportErrors(func(errs ...ResultError) {
+		*reported = append(*reported, errs...)
+	})
+	return e
+}
+
+func TestGuardTripsOnMismatches(t *testing.T) {
+	now := time.Unix(0, 0)
+	var events []GuardEvent
+	g := &Guard{Window: 4, MaxMismatchRate: 0.5, Cooldown: time.Minute}
+	g.now = func() time.Time { return now }
+	g.Notify = func(e GuardEvent) {
+		events = append(events, e)
+	}
+
+	ran := 0
+	var reported []ResultError
+	mismatch := true
+	e := guardedExperiment(g, func() (interface{}, error) {
+		if mismatch {
+			return 2, nil
+		}
+		return 1, nil
+	}, &ran, &reported)
+
+	for i := 0; i < 3; i++ {
+		e.Run()
+	}
+
+	if g.Tripped("guarded") {
+		t.Fatalf("Expected guard to wait for %d samples", g.Window)
+	}
+
+	e.Run()
+	if !g.Tripped("guarded") {
+		t.Fatalf("Expected guard to trip")
+	}
+
+	if len(reported) != 1 || reported[0].Operation != "guard" {
+		t.Fatalf("Unexpected reported errors: %v", reported)
+	}
+
+	event, ok := reported[0].Err.(GuardEvent)
+	if !ok || !event.Tripped || event.Reason != "mismatch rate 1.00 exceeded 0.50" {
+		t.Errorf("Unexpected guard error: %v", reported[0].Err)
+	}
+
+	v, err := e.Run()
+	if v != 1 || err != nil {
+		t.Errorf("Unexpected control result: %v, %v", v, err)
+	}
+
+	if ran != 4 {
+		t.Errorf("Expected tripped guard to skip candidate, ran %d times", ran)
+	}
+
+	now = now.Add(time.Minute)
+	mismatch = false
+	e.Run()
+	if ran != 5 {
+		t.Errorf("Expected candidate to run after cooldown, ran %d times", ran)
+	}
+
+	if g.Tripped("guarded") {
+		t.Errorf("Expected guard to reset after cooldown")
+	}
+
+	if len(events) != 2 || !events[0].Tripped || events[1].Tripped {
+		t.Errorf("Unexpected guard events: %v", events)
+	}
+}
+
+func TestGuardTripsOnErrors(t *testing.T) {
+	g := &Guard{Window: 10, MinSamples: 2, MaxErrorRate: 0.5}
+	ran := 0
+	var reported []ResultError
+	e := guardedExperiment(g, func() (interface{}, error) {
+		return nil, errors.New("broken")
+	}, &ran, &reported)
+
+	e.Run()
+	e.Run()
+	if !g.Tripped("guarded") {
+		t.Fatalf("Expected guard to trip")
+	}
+
+	e.Run()
+	if ran != 2 {
+		t.Errorf("Expected tripped guard to skip candidate, ran %d times", ran)
+	}
+
+	g.Reset("guarded")
+	if g.Tripped("guarded") {
+		t.Errorf("Expected guard to reset")
+	}
+
+	e.Run()
+	if ran != 3 {
+		t.Errorf("Expected candidate to run after reset, ran %d times", ran)
+	}
+}
+
+func TestGuardIgnoresControlErrors(t *testing.T) {
+	g := &Guard{Window: 10, MinSamples: 2, MaxErrorRate: 0.5}
+	ran := 0
+	var reported []ResultError
+	e := guardedExperiment(g, func() (interface{}, error) {
+		return nil, errors.New("not found")
+	}, &ran, &reported)
+	e.Use(func() (interface{}, error) {
+		return nil, errors.New("not found")
+	})
+
+	e.Run()
+	e.Run()
+	if g.Tripped("guarded") {
+		t.Errorf("Expected errors that match the control not to trip the guard")
+	}
+}
+
+func TestGuardNotifyPanic(t *testing.T) {
+	g := &Guard{Window: 10, MinSamples: 1, MaxMismatchRate: 0.5}
+	g.Notify = func(GuardEvent) {
+		panic("notify")
+	}
+
+	ran := 0
+	var reported []ResultError
+	e := guardedExperiment(g, func() (interface{}, error) {
+		return 2, nil
+	}, &ran, &reported)
+
+	e.Run()
+	if !g.Tripped("guarded") {
+		t.Fatalf("Expected guard to trip")
+	}
+
+	var ops []Operation
+	for _, err := range reported {
+		ops = append(ops, err.Operation)
+	}
+
+	if len(ops) != 2 || ops[0] != OperationGuard || ops[1] != OperationGuard+panicSuffix {
+		t.Errorf("Unexpected reported operations: %v", ops)
+	}
+}
+
+func TestGuardTripsOnSlowCandidates(t *testing.T) {
+	g := &Guard{Window: 2, MaxSlowRate: 0.5, SlowThreshold: time.Millisecond}
+	ran := 0
+	var reported []ResultError
+	e := guardedExperiment(g, func() (interface{}, error) {
+		time.Sleep(2 * time.Millisecond)
+		return 1, nil
+	}, &ran, &reported)
+
+	e.Run()
+	e.Run()
+	if !g.Tripped("guarded") {
+		t.Fatalf("Expected guard to trip")
+	}
+
+	if g.Tripped("other") {
+		t.Errorf("Expected guard to track experiments separately")
+	}
+}
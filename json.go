@@ -0,0 +1,167 @@
+package scientist
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// RecordVersion is the version of the ResultRecord schema. It changes whenever
+// fields are removed or change meaning.
+const RecordVersion = 1
+
+const (
+	StatusControl    = "control"
+	StatusMatched    = "matched"
+	StatusIgnored    = "ignored"
+	StatusMismatched = "mismatched"
+)
+
+// ResultRecord is a Result in a stable format for publishing and analysis.
+type ResultRecord struct {
+	Version    int                 `json:"version"`
+	Experiment string              `json:"experiment"`
+	Time       time.Time           `json:"time"`
+	Context    map[string]string   `json:"context,omitempty"`
+	Status     string              `json:"status"`
+	Control    ObservationRecord   `json:"control"`
+	Candidates []ObservationRecord `json:"candidates"`
+}
+
+// ObservationRecord is an Observation in a ResultRecord. Value is the cleaned
+// value of the observation, encoded as JSON.
+type ObservationRecord struct {
+	Name       string             `json:"name"`
+	Index      int                `json:"index"`
+	Status     string             `json:"status"`
+	Runtime    time.Duration      `json:"runtime_ns"`
+	Value      json.RawMessage    `json:"value"`
+	ValueError string             `json:"value_error,omitempty"`
+	Error      string             `json:"error,omitempty"`
+	TimedOut   bool               `json:"timed_out,omitempty"`
+	Diff       []DifferenceRecord `json:"diff,omitempty"`
+	Resources  *ResourceUsage     `json:"resources,omitempty"`
+}
+
+// DifferenceRecord is a Difference in an ObservationRecord, with formatted
+// values.
+type DifferenceRecord struct {
+	Path      string `json:"path"`
+	Control   string `json:"control"`
+	Candidate string `json:"candidate"`
+}
+
+func NewResultRecord[T any](r TypedResult[T]) ResultRecord {
+	rec := ResultRecord{
+		Version:    RecordVersion,
+		Experiment: r.Experiment.Name,
+		Context:    r.Experiment.Context,
+		Status:     StatusMatched,
+		Candidates: make([]ObservationRecord, len(r.Candidates)),
+	}
+
+	if r.IsMismatched() {
+		rec.Status = StatusMismatched
+	} else if r.IsIgnored() {
+		rec.Status = StatusIgnored
+	}
+
+	if r.Control != nil {
+		rec.Time = r.Control.Started
+		rec.Control = newObservationRecord(r.Control, StatusControl)
+	}
+
+	for i, c := range r.Candidates {
+		rec.Candidates[i] = newObservationRecord(c, candidateStatus(r, c))
+	}
+
+	return rec
+}
+
+func candidateStatus[T any](r TypedResult[T], c *TypedObservation[T]) string {
+	for _, o := range r.Mismatched {
+		if o == c {
+			return StatusMismatched
+		}
+	}
+
+	for _, o := range r.Ignored {
+		if o == c {
+			return StatusIgnored
+		}
+	}
+
+	return StatusMatched
+}
+
+func newObservationRecord[T any](o *TypedObservation[T], status string) ObservationRecord {
+	rec := ObservationRecord{
+		Name:      o.Name,
+		Index:     o.Index,
+		Status:    status,
+		Runtime:   o.Runtime,
+		TimedOut:  o.TimedOut,
+		Value:     json.RawMessage("null"),
+		Resources: o.Resources,
+	}
+
+	// errored observations aren't cleaned, like in Run.
+	if o.Err != nil {
+		rec.Error = o.Err.Error()
+	} else if cleaned, err := o.CleanedValue(); err != nil {
+		rec.ValueError = err.Error()
+	} else if data, err := json.Marshal(cleaned); err != nil {
+		rec.ValueError = err.Error()
+	} else {
+		rec.Value = data
+	}
+
+	for _, d := range o.Diff {
+		rec.Diff = append(rec.Diff, DifferenceRecord{
+			Path:      d.Path,
+			Control:   formatDiffValue(d.Control),
+			Candidate: formatDiffValue(d.Candidate),
+		})
+	}
+
+	return rec
+}
+
+// JSONPublisher writes each published result as a line of JSON. It's safe to
+// share between goroutines and experiments.
+//
+//	var publisher = scientist.NewJSONPublisher(os.Stdout)
+//
+//	experiment.Publish(publisher.Publish)
+type JSONPublisher struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewJSONPublisher(w io.Writer) *JSONPublisher {
+	return &JSONPublisher{enc: json.NewEncoder(w)}
+}
+
+func (p *JSONPublisher) Publish(r Result) error {
+	return p.PublishRecord(NewResultRecord(r))
+}
+
+func (p *JSONPublisher) PublishRecord(rec ResultRecord) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.enc.Encode(rec); err != nil {
+		return fmt.Errorf("[scientist] error writing JSON result: %w", err)
+	}
+	return nil
+}
+
+// PublishRecords adapts a ResultRecord publisher, like
+// JSONPublisher.PublishRecord, for a typed experiment's Publish callback.
+func PublishRecords[T any](fn func(ResultRecord) error) func(TypedResult[T]) error {
+	return func(r TypedResult[T]) error {
+		return fn(NewResultRecord(r))
+	}
+}
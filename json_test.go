@@ -0,0 +1,159 @@
+package scientist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestJSONPublisher(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewJSONPublisher(&buf)
+
+	e := New("json")
+	e.Context["user"] = "1"
+	e.Use(func() (interface{}, error) {
+		return map[string]int{"a": 1}, nil
+	})
+	e.Try(func() (interface{}, error) {
+		return map[string]int{"a": 2}, nil
+	})
+	e.Behavior("broken", func() (interface{}, error) {
+		return nil, errors.New("broken")
+	})
+	e.Behavior("func", func() (interface{}, error) {
+		return func() {}, nil
+	})
+	e.Ignore(func(control, candidate interface{}) (bool, error) {
+		return candidate == nil, nil
+	})
+	e.Publish(p.Publish)
+	e.Run()
+
+	var rec ResultRecord
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("Unexpected JSON error: %v\n%s", err, buf.String())
+	}
+
+	if rec.Version != RecordVersion || rec.Experiment != "json" || rec.Context["user"] != "1" {
+		t.Errorf("Unexpected result record: %+v", rec)
+	}
+
+	if rec.Status != StatusMismatched {
+		t.Errorf("Unexpected status: %q", rec.Status)
+	}
+
+	if rec.Control.Status != StatusControl || string(rec.Control.Value) != `{"a":1}` {
+		t.Errorf("Unexpected control record: %+v", rec.Control)
+	}
+
+	if len(rec.Candidates) != 3 {
+		t.Fatalf("Unexpected candidate records: %+v", rec.Candidates)
+	}
+
+	broken := rec.Candidates[0]
+	if broken.Name != "broken" || broken.Status != StatusIgnored || broken.Error != "broken" {
+		t.Errorf("Unexpected broken record: %+v", broken)
+	}
+
+	candidate := rec.Candidates[1]
+	if candidate.Name != "candidate" || candidate.Status != StatusMismatched || string(candidate.Value) != `{"a":2}` {
+		t.Errorf("Unexpected candidate record: %+v", candidate)
+	}
+
+	if len(candidate.Diff) != 1 || candidate.Diff[0] != (DifferenceRecord{`["a"]`, "1", "2"}) {
+		t.Errorf("Unexpected candidate diff: %+v", candidate.Diff)
+	}
+
+	fn := rec.Candidates[2]
+	if fn.Name != "func" || string(fn.Value) != "null" || fn.ValueError == "" {
+		t.Errorf("Unexpected func record: %+v", fn)
+	}
+}
+
+func TestJSONPublisherTyped(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewJSONPublisher(&buf)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e := NewTyped[string]("typed")
+			e.Use(func() (string, error) {
+				return "a", nil
+			})
+			e.Try(func() (string, error) {
+				return "a", nil
+			})
+			e.Clean(func(v string) (interface{}, error) {
+				return strings.ToUpper(v), nil
+			})
+			e.Publish(PublishRecords[string](p.PublishRecord))
+			e.Run()
+		}()
+	}
+	wg.Wait()
+
+	lines := 0
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		lines += 1
+		var rec ResultRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("Unexpected JSON error: %v\n%s", err, scanner.Text())
+		}
+
+		if rec.Status != StatusMatched || string(rec.Candidates[0].Value) != `"A"` {
+			t.Errorf("Unexpected result record: %+v", rec)
+		}
+	}
+
+	if lines != 10 {
+		t.Errorf("Expected 10 lines, got %d", lines)
+	}
+}
+
+func TestResultRecordErroredObservation(t *testing.T) {
+	type widget struct {
+		Name string
+	}
+
+	var records []ResultRecord
+	e := NewTyped[*widget]("errored")
+	e.Use(func() (*widget, error) {
+		return &widget{Name: "a"}, nil
+	})
+	e.Try(func() (*widget, error) {
+		return nil, errors.New("boom")
+	})
+	e.Clean(func(w *widget) (interface{}, error) {
+		return w.Name, nil
+	})
+	e.ReportErrors(func(errs ...ResultError) {
+		t.Errorf("Unexpected errors: %v", errs)
+	})
+	e.Publish(PublishRecords[*widget](func(rec ResultRecord) error {
+		records = append(records, rec)
+		return nil
+	}))
+	e.Run()
+
+	if len(records) != 1 {
+		t.Fatalf("Unexpected records: %v", records)
+	}
+
+	if v := string(records[0].Control.Value); v != `"a"` {
+		t.Errorf("Unexpected control value: %s", v)
+	}
+
+	c := records[0].Candidates[0]
+	if c.Error != "boom" || c.ValueError != "" || string(c.Value) != "null" {
+		t.Errorf("Unexpected candidate record: %+v", c)
+	}
+}
@@ -0,0 +1,429 @@
+package scientist
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// histogramGrowth is the ratio between the bounds of each histogram bucket, so
+// recorded runtimes are accurate to within 2%.
+const histogramGrowth = 1.02
+
+var logHistogramGrowth = math.Log(histogramGrowth)
+
+// Histogram counts runtimes in logarithmic buckets. Histograms can be merged,
+// such as to combine several time periods. It isn't safe to share between
+// goroutines.
+type Histogram struct {
+	counts map[int]int64
+	count  int64
+	sum    time.Duration
+	min    time.Duration
+	max    time.Duration
+}
+
+func NewHistogram() *Histogram {
+	return &Histogram{counts: make(map[int]int64)}
+}
+
+func (h *Histogram) Add(d time.Duration) {
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if h.count == 0 || d > h.max {
+		h.max = d
+	}
+
+	if h.counts == nil {
+		h.counts = make(map[int]int64)
+	}
+	h.counts[histogramBucket(d)]++
+	h.count++
+	h.sum += d
+}
+
+// Merge adds every runtime counted by other.
+func (h *Histogram) Merge(other *Histogram) {
+	if other.count == 0 {
+		return
+	}
+
+	if h.count == 0 || other.min < h.min {
+		h.min = other.min
+	}
+	if h.count == 0 || other.max > h.max {
+		h.max = other.max
+	}
+
+	if h.counts == nil {
+		h.counts = make(map[int]int64)
+	}
+	for b, n := range other.counts {
+		h.counts[b] += n
+	}
+	h.count += other.count
+	h.sum += other.sum
+}
+
+func (h *Histogram) Count() int64 {
+	return h.count
+}
+
+func (h *Histogram) Mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return h.sum / time.Duration(h.count)
+}
+
+// Percentile returns the nearest-rank percentile, from 0 to 100, of the
+// counted runtimes.
+func (h *Histogram) Percentile(p float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+
+	rank := int64(math.Ceil(p / 100 * float64(h.count)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen int64
+	for _, b := range h.buckets() {
+		seen += h.counts[b]
+		if seen >= rank {
+			d := bucketValue(b)
+			if d < h.min {
+				return h.min
+			}
+			if d > h.max {
+				return h.max
+			}
+			return d
+		}
+	}
+	return h.max
+}
+
+func (h *Histogram) clone() *Histogram {
+	c := NewHistogram()
+	c.Merge(h)
+	return c
+}
+
+func (h *Histogram) buckets() []int {
+	buckets := make([]int, 0, len(h.counts))
+	for b := range h.counts {
+		buckets = append(buckets, b)
+	}
+	sort.Ints(buckets)
+	return buckets
+}
+
+func histogramBucket(d time.Duration) int {
+	if d < 1 {
+		return 0
+	}
+	return 1 + int(math.Log(float64(d))/logHistogramGrowth)
+}
+
+// bucketValue returns the geometric middle of a bucket.
+func bucketValue(b int) time.Duration {
+	if b == 0 {
+		return 0
+	}
+	return time.Duration(math.Exp((float64(b) - 0.5) * logHistogramGrowth))
+}
+
+// LatencyAggregator collects the runtimes of each experiment's control and
+// candidates, and tests whether candidates are significantly slower or faster
+// than the control. It's safe to share between goroutines and experiments.
+//
+//	var latency = scientist.NewLatencyAggregator()
+//
+//	experiment.Publish(latency.Publish)
+//
+//	for _, c := range latency.Comparisons() {
+//	  if c.Slower {
+//	    log.Printf("%s/%s is slower: p50 %v -> %v", c.Experiment, c.Candidate, c.Control.P50, c.CandidateLatency.P50)
+//	  }
+//	}
+type LatencyAggregator struct {
+	// Alpha is the significance level of the comparisons. Defaults to 0.05.
+	Alpha float64
+
+	mu          sync.Mutex
+	experiments map[string]*experimentLatency
+}
+
+type experimentLatency struct {
+	control    *Histogram
+	candidates map[string]*Histogram
+}
+
+// LatencyStats summarizes a Histogram.
+type LatencyStats struct {
+	Count int64         `json:"count"`
+	Mean  time.Duration `json:"mean_ns"`
+	P50   time.Duration `json:"p50_ns"`
+	P90   time.Duration `json:"p90_ns"`
+	P99   time.Duration `json:"p99_ns"`
+}
+
+// LatencyComparison compares a candidate's runtimes with its control's using
+// a Mann-Whitney U test. Runtimes within 2% of each other count as ties.
+type LatencyComparison struct {
+	Experiment       string       `json:"experiment"`
+	Candidate        string       `json:"candidate"`
+	Control          LatencyStats `json:"control"`
+	CandidateLatency LatencyStats `json:"candidate_latency"`
+
+	// Z is the normal approximation of the U statistic. It's positive if the
+	// candidate tends to be slower than the control.
+	Z float64 `json:"z"`
+
+	// PValue is the two-sided probability of seeing a difference at least this
+	// large if the candidate and control were equally fast.
+	PValue float64 `json:"p_value"`
+
+	// Slower or Faster is set if PValue is below the aggregator's Alpha.
+	Slower bool `json:"slower"`
+	Faster bool `json:"faster"`
+}
+
+func NewLatencyAggregator() *LatencyAggregator {
+	return &LatencyAggregator{experiments: make(map[string]*experimentLatency)}
+}
+
+func (a *LatencyAggregator) Publish(r Result) error {
+	return publishLatency(a, r)
+}
+
+// PublishLatency returns a publisher that adds the runtimes of a typed
+// experiment's results to a:
+//
+//	experiment.Publish(scientist.PublishLatency[bool](latency))
+func PublishLatency[T any](a *LatencyAggregator) func(TypedResult[T]) error {
+	return func(r TypedResult[T]) error {
+		return publishLatency(a, r)
+	}
+}
+
+func publishLatency[T any](a *LatencyAggregator, r TypedResult[T]) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	exp := a.experiment(r.Experiment.Name)
+	exp.control.Add(r.Control.Runtime)
+	for _, c := range r.Candidates {
+		exp.candidate(c.Name).Add(c.Runtime)
+	}
+	return nil
+}
+
+// PublishRecord adds the runtimes in rec, such as from records read back from
+// a JSONPublisher log.
+func (a *LatencyAggregator) PublishRecord(rec ResultRecord) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	exp := a.experiment(rec.Experiment)
+	exp.control.Add(rec.Control.Runtime)
+	for _, c := range rec.Candidates {
+		exp.candidate(c.Name).Add(c.Runtime)
+	}
+	return nil
+}
+
+// Merge adds every runtime collected by other.
+func (a *LatencyAggregator) Merge(other *LatencyAggregator) {
+	if a == other {
+		return
+	}
+
+	other.mu.Lock()
+	copies := make(map[string]*experimentLatency, len(other.experiments))
+	for name, exp := range other.experiments {
+		copies[name] = exp.clone()
+	}
+	other.mu.Unlock()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for name, exp := range copies {
+		mine := a.experiment(name)
+		mine.control.Merge(exp.control)
+		for cname, h := range exp.candidates {
+			mine.candidate(cname).Merge(h)
+		}
+	}
+}
+
+// Histograms returns copies of the control and candidate histograms for the
+// named experiment.
+func (a *LatencyAggregator) Histograms(experiment string) (*Histogram, map[string]*Histogram) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	exp, ok := a.experiments[experiment]
+	if !ok {
+		return NewHistogram(), map[string]*Histogram{}
+	}
+
+	c := exp.clone()
+	return c.control, c.candidates
+}
+
+// Compare compares the runtimes of an experiment's candidate with its
+// control.
+func (a *LatencyAggregator) Compare(experiment, candidate string) LatencyComparison {
+	control, candidates := a.Histograms(experiment)
+	cand, ok := candidates[candidate]
+	if !ok {
+		cand = NewHistogram()
+	}
+	return a.compare(experiment, candidate, control, cand)
+}
+
+// Comparisons compares every candidate with its control, sorted by experiment
+// and candidate name.
+func (a *LatencyAggregator) Comparisons() []LatencyComparison {
+	a.mu.Lock()
+	names := make([]string, 0, len(a.experiments))
+	for name := range a.experiments {
+		names = append(names, name)
+	}
+	a.mu.Unlock()
+	sort.Strings(names)
+
+	var comparisons []LatencyComparison
+	for _, name := range names {
+		control, candidates := a.Histograms(name)
+		cnames := make([]string, 0, len(candidates))
+		for cname := range candidates {
+			cnames = append(cnames, cname)
+		}
+		sort.Strings(cnames)
+
+		for _, cname := range cnames {
+			comparisons = append(comparisons, a.compare(name, cname, control, candidates[cname]))
+		}
+	}
+	return comparisons
+}
+
+func (a *LatencyAggregator) compare(experiment, candidate string, control, cand *Histogram) LatencyComparison {
+	c := LatencyComparison{
+		Experiment:       experiment,
+		Candidate:        candidate,
+		Control:          latencyStats(control),
+		CandidateLatency: latencyStats(cand),
+		PValue:           1,
+	}
+
+	c.Z = mannWhitneyZ(control, cand)
+	if c.Z != 0 {
+		c.PValue = math.Erfc(math.Abs(c.Z) / math.Sqrt2)
+	}
+
+	alpha := a.Alpha
+	if alpha <= 0 {
+		alpha = 0.05
+	}
+
+	if c.PValue < alpha {
+		c.Slower = c.Z > 0
+		c.Faster = c.Z < 0
+	}
+	return c
+}
+
+func (a *LatencyAggregator) experiment(name string) *experimentLatency {
+	if a.experiments == nil {
+		a.experiments = make(map[string]*experimentLatency)
+	}
+
+	exp, ok := a.experiments[name]
+	if !ok {
+		exp = &experimentLatency{
+			control:    NewHistogram(),
+			candidates: make(map[string]*Histogram),
+		}
+		a.experiments[name] = exp
+	}
+	return exp
+}
+
+func (exp *experimentLatency) candidate(name string) *Histogram {
+	h, ok := exp.candidates[name]
+	if !ok {
+		h = NewHistogram()
+		exp.candidates[name] = h
+	}
+	return h
+}
+
+func (exp *experimentLatency) clone() *experimentLatency {
+	c := &experimentLatency{
+		control:    exp.control.clone(),
+		candidates: make(map[string]*Histogram, len(exp.candidates)),
+	}
+	for name, h := range exp.candidates {
+		c.candidates[name] = h.clone()
+	}
+	return c
+}
+
+func latencyStats(h *Histogram) LatencyStats {
+	return LatencyStats{
+		Count: h.Count(),
+		Mean:  h.Mean(),
+		P50:   h.Percentile(50),
+		P90:   h.Percentile(90),
+		P99:   h.Percentile(99),
+	}
+}
+
+// mannWhitneyZ returns the normal approximation of the Mann-Whitney U
+// statistic for the candidate, with a correction for ties. Runtimes in the
+// same bucket are ties.
+func mannWhitneyZ(control, candidate *Histogram) float64 {
+	n1, n2 := float64(control.count), float64(candidate.count)
+	if n1 == 0 || n2 == 0 {
+		return 0
+	}
+
+	buckets := make(map[int]bool, len(control.counts)+len(candidate.counts))
+	for b := range control.counts {
+		buckets[b] = true
+	}
+	for b := range candidate.counts {
+		buckets[b] = true
+	}
+
+	sorted := make([]int, 0, len(buckets))
+	for b := range buckets {
+		sorted = append(sorted, b)
+	}
+	sort.Ints(sorted)
+
+	var u, ties, controlBelow float64
+	for _, b := range sorted {
+		c1, c2 := float64(control.counts[b]), float64(candidate.counts[b])
+		u += c2 * (controlBelow + c1/2)
+		controlBelow += c1
+
+		t := c1 + c2
+		ties += t*t*t - t
+	}
+
+	n := n1 + n2
+	variance := n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1)))
+	if variance <= 0 {
+		return 0
+	}
+	return (u - n1*n2/2) / math.Sqrt(variance)
+}
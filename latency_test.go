@@ -0,0 +1,137 @@
+package scientist
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram()
+	for i := 1; i <= 100; i++ {
+		h.Add(time.Duration(i) * time.Millisecond)
+	}
+
+	if h.Count() != 100 || h.Mean() != 50500*time.Microsecond {
+		t.Errorf("Unexpected count and mean: %d, %v", h.Count(), h.Mean())
+	}
+
+	for _, p := range []float64{0, 50, 90, 99, 100} {
+		expected := time.Duration(p) * time.Millisecond
+		if p == 0 {
+			expected = time.Millisecond
+		}
+
+		if d := h.Percentile(p); d < expected*98/100 || d > expected*102/100 {
+			t.Errorf("Unexpected p%v: %v", p, d)
+		}
+	}
+
+	var other Histogram
+	other.Add(0)
+	other.Add(time.Second)
+	h.Merge(&other)
+
+	if h.Count() != 102 || h.Percentile(0) != 0 || h.Percentile(100) != time.Second {
+		t.Errorf("Unexpected merged histogram: %d, %v, %v", h.Count(), h.Percentile(0), h.Percentile(100))
+	}
+}
+
+func latencyRecord(control, candidate time.Duration) ResultRecord {
+	return ResultRecord{
+		Experiment: "latency",
+		Control:    ObservationRecord{Name: "control", Runtime: control},
+		Candidates: []ObservationRecord{{Name: "candidate", Runtime: candidate}},
+	}
+}
+
+func TestLatencyAggregator(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	jitter := func(d time.Duration) time.Duration {
+		return d + time.Duration(r.Int63n(int64(d)))
+	}
+
+	same, slower, faster := NewLatencyAggregator(), NewLatencyAggregator(), NewLatencyAggregator()
+	for i := 0; i < 200; i++ {
+		same.PublishRecord(latencyRecord(jitter(time.Millisecond), jitter(time.Millisecond)))
+		slower.PublishRecord(latencyRecord(jitter(time.Millisecond), jitter(2*time.Millisecond)))
+		faster.PublishRecord(latencyRecord(jitter(time.Millisecond), jitter(time.Millisecond/2)))
+	}
+
+	if c := same.Compare("latency", "candidate"); c.Slower || c.Faster || c.PValue < 0.05 {
+		t.Errorf("Unexpected comparison of the same latency: %+v", c)
+	}
+
+	if c := slower.Compare("latency", "candidate"); !c.Slower || c.Faster || c.Z <= 0 {
+		t.Errorf("Unexpected comparison of a slower candidate: %+v", c)
+	}
+
+	c := faster.Compare("latency", "candidate")
+	if c.Slower || !c.Faster || c.Z >= 0 {
+		t.Errorf("Unexpected comparison of a faster candidate: %+v", c)
+	}
+
+	if c.Control.Count != 200 || c.CandidateLatency.Count != 200 || c.CandidateLatency.P50 >= c.Control.P50 {
+		t.Errorf("Unexpected latency stats: %+v, %+v", c.Control, c.CandidateLatency)
+	}
+
+	if c := NewLatencyAggregator().Compare("latency", "candidate"); c.PValue != 1 || c.Slower || c.Faster {
+		t.Errorf("Unexpected comparison without runtimes: %+v", c)
+	}
+}
+
+func TestLatencyAggregatorMerge(t *testing.T) {
+	a, b := NewLatencyAggregator(), NewLatencyAggregator()
+	a.PublishRecord(latencyRecord(time.Millisecond, 2*time.Millisecond))
+	b.PublishRecord(latencyRecord(time.Millisecond, 2*time.Millisecond))
+	b.PublishRecord(ResultRecord{Experiment: "other", Control: ObservationRecord{Name: "control"}})
+
+	a.Merge(b)
+	a.Merge(a)
+
+	control, candidates := a.Histograms("latency")
+	if control.Count() != 2 || candidates["candidate"].Count() != 2 {
+		t.Errorf("Unexpected merged counts: %d, %v", control.Count(), candidates)
+	}
+
+	comparisons := a.Comparisons()
+	if len(comparisons) != 1 || comparisons[0].Experiment != "latency" || comparisons[0].Candidate != "candidate" {
+		t.Errorf("Unexpected comparisons: %+v", comparisons)
+	}
+}
+
+func TestLatencyAggregatorPublish(t *testing.T) {
+	a := NewLatencyAggregator()
+	e := basicExperiment()
+	e.Publish(a.Publish)
+	e.Run()
+
+	control, candidates := a.Histograms("basic")
+	if control.Count() != 1 || len(candidates) != 3 {
+		t.Errorf("Unexpected histograms: %d, %v", control.Count(), candidates)
+	}
+}
+
+func TestPublishLatency(t *testing.T) {
+	a := NewLatencyAggregator()
+	e := NewTyped[int]("latency.typed")
+	e.Use(func() (int, error) {
+		return 1, nil
+	})
+	e.Try(func() (int, error) {
+		time.Sleep(time.Millisecond)
+		return 1, nil
+	})
+	e.Publish(PublishLatency[int](a))
+	e.Run()
+	e.Run()
+
+	control, candidates := a.Histograms("latency.typed")
+	if control.Count() != 2 || len(candidates) != 1 || candidates["candidate"].Count() != 2 {
+		t.Fatalf("Unexpected histograms: %d, %v", control.Count(), candidates)
+	}
+
+	if candidates["candidate"].Percentile(50) < 900*time.Microsecond {
+		t.Errorf("Unexpected candidate runtime: %v", candidates["candidate"].Percentile(50))
+	}
+}
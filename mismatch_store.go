@@ -0,0 +1,323 @@
+package scientist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	mismatchFilePrefix = "mismatches-"
+	mismatchFileSuffix = ".jsonl"
+)
+
+// MismatchStore saves mismatched results as ResultRecords in rotating JSON
+// Lines files, so they can be reproduced later. Matched and ignored results
+// are skipped. It's safe to share between goroutines and experiments, but not
+// between processes.
+//
+//	var mismatches = &scientist.MismatchStore{Dir: "log/mismatches", MaxFiles: 5}
+//
+//	experiment.Publish(mismatches.Publish)
+type MismatchStore struct {
+	Dir string
+
+	// MaxFileSize is the size in bytes that a file may reach before the store
+	// rotates to a new one. Defaults to 10MB.
+	MaxFileSize int64
+
+	// MaxFileEntries is the number of results that a file may hold before the
+	// store rotates to a new one. A file's entries are only limited by
+	// MaxFileSize if this is zero.
+	MaxFileEntries int
+
+	// MaxFiles is the number of files to keep. The oldest file is removed
+	// when the store rotates past it. Defaults to 10.
+	MaxFiles int
+
+	mu      sync.Mutex
+	file    *os.File
+	seq     int
+	size    int64
+	entries int
+}
+
+// Publish saves the result if any of its candidates mismatched.
+func (s *MismatchStore) Publish(r Result) error {
+	if !r.IsMismatched() {
+		return nil
+	}
+	return s.PublishRecord(NewResultRecord(r))
+}
+
+// PublishRecord saves the record if its status is mismatched. Use it with
+// PublishRecords for typed experiments.
+func (s *MismatchStore) PublishRecord(rec ResultRecord) error {
+	if rec.Status != StatusMismatched {
+		return nil
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("[scientist] error encoding mismatch: %w", err)
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.open(); err != nil {
+		return err
+	}
+
+	if s.full(int64(len(data))) {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(data)
+	s.size += int64(n)
+	s.entries++
+	if err != nil {
+		return fmt.Errorf("[scientist] error writing mismatch: %w", err)
+	}
+	return nil
+}
+
+// Query returns the stored records for the named experiment that started
+// within [from, to), oldest first. An empty name matches every experiment, and
+// a zero from or to leaves that end of the range open.
+func (s *MismatchStore) Query(experiment string, from, to time.Time) ([]ResultRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seqs, err := s.files()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []ResultRecord
+	for _, seq := range seqs {
+		f, err := os.Open(s.path(seq))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("[scientist] error reading mismatches: %w", err)
+		}
+
+		err = readRecords(f, func(rec ResultRecord) {
+			if experiment != "" && rec.Experiment != experiment {
+				return
+			}
+			if !from.IsZero() && rec.Time.Before(from) {
+				return
+			}
+			if !to.IsZero() && !rec.Time.Before(to) {
+				return
+			}
+			records = append(records, rec)
+		})
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("[scientist] error reading %s: %w", s.path(seq), err)
+		}
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+	return records, nil
+}
+
+// Close closes the current file. The store opens it again if more mismatches
+// are published.
+func (s *MismatchStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// open opens the newest file in Dir, creating Dir if needed.
+func (s *MismatchStore) open() error {
+	if s.file != nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return fmt.Errorf("[scientist] error creating mismatch store: %w", err)
+	}
+
+	seqs, err := s.files()
+	if err != nil {
+		return err
+	}
+
+	s.seq = 1
+	if len(seqs) > 0 {
+		s.seq = seqs[len(seqs)-1]
+	}
+	return s.openSeq()
+}
+
+func (s *MismatchStore) openSeq() error {
+	f, err := os.OpenFile(s.path(s.seq), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("[scientist] error opening mismatch store: %w", err)
+	}
+
+	s.size = 0
+	s.entries = 0
+	err = readRecords(f, func(ResultRecord) {
+		s.entries++
+	})
+	if err == nil {
+		err = endLine(f)
+	}
+	if err == nil {
+		s.size, err = f.Seek(0, io.SeekEnd)
+	}
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("[scientist] error opening mismatch store: %w", err)
+	}
+
+	s.file = f
+	return nil
+}
+
+// full checks if writing n more bytes to the current file would pass its caps.
+// A file always gets at least one entry.
+func (s *MismatchStore) full(n int64) bool {
+	if s.entries == 0 {
+		return false
+	}
+
+	if s.MaxFileEntries > 0 && s.entries >= s.MaxFileEntries {
+		return true
+	}
+
+	maxSize := s.MaxFileSize
+	if maxSize <= 0 {
+		maxSize = 10 << 20
+	}
+	return s.size+n > maxSize
+}
+
+// rotate starts a new file, and removes the oldest files past MaxFiles.
+func (s *MismatchStore) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("[scientist] error rotating mismatch store: %w", err)
+	}
+	s.file = nil
+	s.seq++
+
+	if err := s.openSeq(); err != nil {
+		return err
+	}
+
+	seqs, err := s.files()
+	if err != nil {
+		return err
+	}
+
+	maxFiles := s.MaxFiles
+	if maxFiles <= 0 {
+		maxFiles = 10
+	}
+
+	for len(seqs) > maxFiles {
+		if err := os.Remove(s.path(seqs[0])); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("[scientist] error rotating mismatch store: %w", err)
+		}
+		seqs = seqs[1:]
+	}
+	return nil
+}
+
+// files returns the sequence numbers of the files in Dir, oldest first.
+func (s *MismatchStore) files() ([]int, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("[scientist] error listing mismatch store: %w", err)
+	}
+
+	var seqs []int
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, mismatchFilePrefix) || !strings.HasSuffix(name, mismatchFileSuffix) {
+			continue
+		}
+
+		seq, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, mismatchFilePrefix), mismatchFileSuffix))
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+
+	sort.Ints(seqs)
+	return seqs, nil
+}
+
+func (s *MismatchStore) path(seq int) string {
+	return filepath.Join(s.Dir, fmt.Sprintf("%s%06d%s", mismatchFilePrefix, seq, mismatchFileSuffix))
+}
+
+// endLine ends a line that was cut short, so the next record starts on its
+// own line.
+func endLine(f *os.File) error {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil || size == 0 {
+		return err
+	}
+
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, size-1); err != nil || last[0] == '\n' {
+		return err
+	}
+	_, err = f.Write([]byte{'\n'})
+	return err
+}
+
+// readRecords calls fn with each record in r, skipping lines that aren't
+// valid records, such as one cut short by a crash.
+func readRecords(r io.Reader, fn func(ResultRecord)) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var rec ResultRecord
+			if json.Unmarshal(line, &rec) == nil {
+				fn(rec)
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
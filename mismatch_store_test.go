@@ -0,0 +1,166 @@
+package scientist
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func mismatchRecord(name string, t time.Time) ResultRecord {
+	return ResultRecord{
+		Version:    RecordVersion,
+		Experiment: name,
+		Time:       t,
+		Status:     StatusMismatched,
+		Control:    ObservationRecord{Name: "control", Value: []byte("1")},
+	}
+}
+
+func TestMismatchStore(t *testing.T) {
+	s := &MismatchStore{Dir: filepath.Join(t.TempDir(), "mismatches")}
+
+	e := New("store")
+	e.Context["user"] = "1"
+	e.Use(func() (interface{}, error) {
+		return map[string]int{"a": 1}, nil
+	})
+	e.Try(func() (interface{}, error) {
+		return map[string]int{"a": 2}, nil
+	})
+	e.Behavior("broken", func() (interface{}, error) {
+		return nil, errors.New("broken")
+	})
+	e.Publish(s.Publish)
+	e.Run()
+
+	matched := New("store")
+	matched.Use(func() (interface{}, error) {
+		return 1, nil
+	})
+	matched.Try(func() (interface{}, error) {
+		return 1, nil
+	})
+	matched.Publish(s.Publish)
+	matched.Run()
+
+	records, err := s.Query("store", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("Unexpected query error: %v", err)
+	}
+
+	if len(records) != 1 {
+		t.Fatalf("Expected only the mismatch to be stored: %+v", records)
+	}
+
+	rec := records[0]
+	if rec.Context["user"] != "1" || rec.Time.IsZero() || string(rec.Control.Value) != `{"a":1}` {
+		t.Errorf("Unexpected record: %+v", rec)
+	}
+
+	if len(rec.Candidates) != 2 || rec.Candidates[0].Error != "broken" || len(rec.Candidates[1].Diff) != 1 {
+		t.Errorf("Unexpected candidate records: %+v", rec.Candidates)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Errorf("Unexpected close error: %v", err)
+	}
+}
+
+func TestMismatchStoreQuery(t *testing.T) {
+	s := &MismatchStore{Dir: t.TempDir()}
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 4; i++ {
+		for _, name := range []string{"a", "b"} {
+			if err := s.PublishRecord(mismatchRecord(name, start.Add(time.Duration(i)*time.Hour))); err != nil {
+				t.Fatalf("Unexpected publish error: %v", err)
+			}
+		}
+	}
+
+	matched := mismatchRecord("a", start)
+	matched.Status = StatusMatched
+	s.PublishRecord(matched)
+
+	records, err := s.Query("a", start.Add(time.Hour), start.Add(3*time.Hour))
+	if err != nil {
+		t.Fatalf("Unexpected query error: %v", err)
+	}
+
+	if len(records) != 2 || records[0].Time != start.Add(time.Hour) || records[1].Time != start.Add(2*time.Hour) {
+		t.Errorf("Unexpected records: %+v", records)
+	}
+
+	if records, _ := s.Query("", time.Time{}, time.Time{}); len(records) != 8 {
+		t.Errorf("Expected all records, got %d", len(records))
+	}
+}
+
+func TestMismatchStoreRotate(t *testing.T) {
+	dir := t.TempDir()
+	s := &MismatchStore{Dir: dir, MaxFileEntries: 2, MaxFiles: 2}
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 7; i++ {
+		if err := s.PublishRecord(mismatchRecord("a", start.Add(time.Duration(i)*time.Minute))); err != nil {
+			t.Fatalf("Unexpected publish error: %v", err)
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if len(files) != 2 {
+		t.Errorf("Unexpected files: %v", files)
+	}
+
+	records, err := s.Query("a", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("Unexpected query error: %v", err)
+	}
+
+	if len(records) != 3 || records[0].Time != start.Add(4*time.Minute) {
+		t.Errorf("Expected the newest records to be kept: %+v", records)
+	}
+
+	// a new store picks up where the last one left off
+	s.Close()
+	s = &MismatchStore{Dir: dir, MaxFileEntries: 2, MaxFiles: 2}
+	s.PublishRecord(mismatchRecord("a", start.Add(7*time.Minute)))
+	s.PublishRecord(mismatchRecord("a", start.Add(8*time.Minute)))
+
+	records, _ = s.Query("a", time.Time{}, time.Time{})
+	if len(records) != 3 || records[0].Time != start.Add(6*time.Minute) {
+		t.Errorf("Unexpected records after reopening: %+v", records)
+	}
+}
+
+func TestMismatchStoreMaxFileSize(t *testing.T) {
+	dir := t.TempDir()
+	s := &MismatchStore{Dir: dir, MaxFileSize: 1, MaxFiles: 3}
+	for i := 0; i < 3; i++ {
+		s.PublishRecord(mismatchRecord("a", time.Now()))
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if len(files) != 3 {
+		t.Fatalf("Expected one record per file: %v", files)
+	}
+
+	// a partial line from a crash is skipped
+	f, _ := os.OpenFile(files[2], os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"experiment":"a",`)
+	f.Close()
+
+	if records, err := s.Query("a", time.Time{}, time.Time{}); len(records) != 3 || err != nil {
+		t.Errorf("Unexpected records: %d, %v", len(records), err)
+	}
+
+	s.Close()
+	s = &MismatchStore{Dir: dir, MaxFiles: 3}
+	s.PublishRecord(mismatchRecord("a", time.Now()))
+
+	if records, err := s.Query("a", time.Time{}, time.Time{}); len(records) != 4 || err != nil {
+		t.Errorf("Expected a record after the partial line: %d, %v", len(records), err)
+	}
+}
@@ -0,0 +1,107 @@
+package scientist
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// MultiPublisher publishes untyped results to several publishers.
+type MultiPublisher = TypedMultiPublisher[interface{}]
+
+// TypedMultiPublisher publishes each result to several named publishers. A
+// publisher that fails or panics doesn't stop the others, and is reported to
+// ReportErrors as its own "publish" error.
+//
+//	publishers := scientist.NewMultiPublisher()
+//	publishers.Add("json", jsonPublisher.Publish)
+//	publishers.Add("statsd", publishStats)
+//	experiment.Publish(publishers.Publish)
+type TypedMultiPublisher[T any] struct {
+	// Concurrent runs the publishers at the same time, instead of one after
+	// another.
+	Concurrent bool
+
+	names      []string
+	publishers []func(TypedResult[T]) error
+}
+
+func NewMultiPublisher() *MultiPublisher {
+	return NewTypedMultiPublisher[interface{}]()
+}
+
+func NewTypedMultiPublisher[T any]() *TypedMultiPublisher[T] {
+	return &TypedMultiPublisher[T]{}
+}
+
+func (p *TypedMultiPublisher[T]) Add(name string, fn func(TypedResult[T]) error) {
+	p.names = append(p.names, name)
+	p.publishers = append(p.publishers, fn)
+}
+
+// Publish calls every publisher, and returns their failures as
+// PublisherErrors.
+func (p *TypedMultiPublisher[T]) Publish(r TypedResult[T]) error {
+	errs := make([]error, len(p.publishers))
+	if p.Concurrent {
+		var wg sync.WaitGroup
+		for i, fn := range p.publishers {
+			wg.Add(1)
+			go func(i int, fn func(TypedResult[T]) error) {
+				defer wg.Done()
+				errs[i] = recoverErr(func() error { return fn(r) })
+			}(i, fn)
+		}
+		wg.Wait()
+	} else {
+		for i, fn := range p.publishers {
+			errs[i] = recoverErr(func() error { return fn(r) })
+		}
+	}
+
+	var failed PublisherErrors
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, PublisherError{Publisher: p.names[i], Err: err})
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+	return failed
+}
+
+// PublisherError is the error from a single publisher of a MultiPublisher.
+type PublisherError struct {
+	Publisher string
+	Err       error
+}
+
+func (e PublisherError) Error() string {
+	return fmt.Sprintf("publisher %q: %v", e.Publisher, e.Err)
+}
+
+func (e PublisherError) Unwrap() error {
+	return e.Err
+}
+
+// PublisherErrors is returned by a MultiPublisher when any of its publishers
+// fail. Each one is reported to ReportErrors separately.
+type PublisherErrors []PublisherError
+
+func (e PublisherErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e PublisherErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
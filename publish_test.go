@@ -1,7 +1,9 @@
 package scientist
 
 import (
+	"errors"
 	"fmt"
+	"sync"
 	"testing"
 )
 
@@ -73,7 +75,7 @@ func TestPublishWithErrors(t *testing.T) {
 	})
 
 	published := false
-	reported := make(map[string]int)
+	reported := make(map[Operation]int)
 	e.Publish(func(r Result) error {
 		published = true
 		return fmt.Errorf("(publish) result: %s", r.Experiment.Name)
@@ -131,3 +133,187 @@ func TestPublishWithErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestPublishWithPanics(t *testing.T) {
+	e := New("publish")
+	e.Use(func() (interface{}, error) {
+		return 1, nil
+	})
+	e.Try(func() (interface{}, error) {
+		return 2, nil
+	})
+	e.BeforeRun(func() error {
+		panic("(before)")
+	})
+	e.Compare(func(control, candidate interface{}) (bool, error) {
+		panic("(compare)")
+	})
+	e.Ignore(func(control, candidate interface{}) (bool, error) {
+		panic("(ignore)")
+	})
+	e.Clean(func(v interface{}) (interface{}, error) {
+		panic("(clean)")
+	})
+
+	published := false
+	reported := make(map[Operation]int)
+	expected := map[Operation]string{
+		"before_run_panic": "(before)",
+		"clean_panic":      "(clean)",
+		"compare_panic":    "(compare)",
+		"ignore_panic":     "(ignore)",
+		"publish_panic":    "(publish)",
+	}
+	e.Publish(func(r Result) error {
+		published = true
+
+		if _, err := r.Control.CleanedValue(); err == nil {
+			t.Errorf("Expected clean panic to be returned")
+		}
+
+		panic("(publish)")
+	})
+
+	e.ReportErrors(func(errors ...ResultError) {
+		for _, err := range errors {
+			reported[err.Operation] = reported[err.Operation] + 1
+			perr, ok := err.Err.(PanicError)
+			if !ok {
+				t.Errorf("Unexpected %q error: %v", err.Operation, err.Err)
+				continue
+			}
+
+			if value, ok := expected[err.Operation]; !ok {
+				t.Errorf("Bad operation: %q", err.Operation)
+			} else if perr.Value != value {
+				t.Errorf("Bad panic value for %q operation: %v", err.Operation, perr.Value)
+			}
+		}
+	})
+
+	v, err := e.Run()
+	if v != 1 {
+		t.Errorf("Unexpected control value: %d", v)
+	}
+
+	if err != nil {
+		t.Errorf("Unexpected control error: %v", err)
+	}
+
+	if !published {
+		t.Errorf("results never published")
+	}
+
+	if len(reported) != 5 || reported["clean_panic"] != 2 {
+		t.Errorf("all result errors not reported: %v", reported)
+	}
+}
+
+func TestMultiPublisher(t *testing.T) {
+	for _, concurrent := range []bool{false, true} {
+		e := New("multi")
+		e.Use(func() (interface{}, error) {
+			return 1, nil
+		})
+		e.Try(func() (interface{}, error) {
+			return 2, nil
+		})
+
+		var mu sync.Mutex
+		published := make(map[string]bool)
+		ok := func(name string) func(Result) error {
+			return func(r Result) error {
+				mu.Lock()
+				published[name] = true
+				mu.Unlock()
+				return nil
+			}
+		}
+
+		publishers := NewMultiPublisher()
+		publishers.Concurrent = concurrent
+		publishers.Add("first", ok("first"))
+		publishers.Add("failing", func(r Result) error {
+			return fmt.Errorf("(failing) result: %s", r.Experiment.Name)
+		})
+		publishers.Add("panicking", func(r Result) error {
+			panic("(panicking)")
+		})
+		publishers.Add("last", ok("last"))
+		e.Publish(publishers.Publish)
+
+		var reported []ResultError
+		e.ReportErrors(func(errors ...ResultError) {
+			reported = append(reported, errors...)
+		})
+
+		v, err := e.Run()
+		if v != 1 || err != nil {
+			t.Errorf("Unexpected control result: %v, %v", v, err)
+		}
+
+		if !published["first"] || !published["last"] {
+			t.Errorf("Expected all publishers to run (concurrent: %v): %v", concurrent, published)
+		}
+
+		if len(reported) != 2 {
+			t.Fatalf("Unexpected reported errors (concurrent: %v): %v", concurrent, reported)
+		}
+
+		failing, ok1 := reported[0].Err.(PublisherError)
+		if reported[0].Operation != "publish" || !ok1 || failing.Publisher != "failing" {
+			t.Errorf("Unexpected failing publisher error: %q %v", reported[0].Operation, reported[0].Err)
+		}
+
+		if actual := reported[0].Error(); actual != `publisher "failing": (failing) result: multi` {
+			t.Errorf("Bad error message for failing publisher: %q", actual)
+		}
+
+		panicking, ok2 := reported[1].Err.(PublisherError)
+		if reported[1].Operation != "publish_panic" || !ok2 || panicking.Publisher != "panicking" {
+			t.Errorf("Unexpected panicking publisher error: %q %v", reported[1].Operation, reported[1].Err)
+		}
+	}
+}
+
+func TestMultiPublisherWrapped(t *testing.T) {
+	e := New("multi.wrapped")
+	e.Use(func() (interface{}, error) {
+		return 1, nil
+	})
+	e.Try(func() (interface{}, error) {
+		return 2, nil
+	})
+
+	errFailed := errors.New("failed")
+	publishers := NewMultiPublisher()
+	publishers.Add("first", func(r Result) error {
+		return errFailed
+	})
+	publishers.Add("second", func(r Result) error {
+		return errFailed
+	})
+	e.Publish(func(r Result) error {
+		err := publishers.Publish(r)
+		if !errors.Is(err, errFailed) {
+			t.Errorf("Expected publisher errors to unwrap: %v", err)
+		}
+		return fmt.Errorf("wrapped: %w", err)
+	})
+
+	var reported []ResultError
+	e.ReportErrors(func(errors ...ResultError) {
+		reported = append(reported, errors...)
+	})
+
+	e.Run()
+	if len(reported) != 2 {
+		t.Fatalf("Unexpected reported errors: %v", reported)
+	}
+
+	for i, name := range []string{"first", "second"} {
+		if perr, ok := reported[i].Err.(PublisherError); !ok || perr.Publisher != name {
+			t.Errorf("Unexpected publisher error: %v", reported[i].Err)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package scientist
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Defaults are callbacks that New sets on every experiment whose name starts
+// with the prefix they are registered with. Nil fields are left alone.
+type Defaults struct {
+	Publish      func(Result) error
+	ReportErrors func(...ResultError)
+	Compare      Comparator
+	Rollout      *Rollout
+	Guard        *Guard
+}
+
+// ExperimentInfo describes an experiment that has been created with New.
+type ExperimentInfo struct {
+	Name string
+
+	// Runs is the number of times the experiment ran its candidates, and
+	// Skips is the number of times it only ran the control.
+	Runs  int64
+	Skips int64
+
+	// Enabled is true if the experiment ran its candidates the last time it
+	// was run.
+	Enabled bool
+}
+
+type registeredDefaults struct {
+	prefix   string
+	defaults Defaults
+}
+
+type experimentStats struct {
+	runs    int64
+	skips   int64
+	enabled int32
+}
+
+var registry struct {
+	mu          sync.RWMutex
+	defaults    []registeredDefaults
+	experiments sync.Map
+}
+
+// SetDefaults registers defaults for experiments whose names start with
+// prefix. Use an empty prefix for every experiment. When several prefixes
+// match an experiment, the longest prefix's fields take precedence.
+//
+//	scientist.SetDefaults("", scientist.Defaults{ReportErrors: reportToSentry})
+//	scientist.SetDefaults("permissions.", scientist.Defaults{Rollout: rollout})
+func SetDefaults(prefix string, d Defaults) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	for i, r := range registry.defaults {
+		if r.prefix == prefix {
+			registry.defaults[i].defaults = d
+			return
+		}
+	}
+
+	registry.defaults = append(registry.defaults, registeredDefaults{prefix, d})
+	sort.SliceStable(registry.defaults, func(i, j int) bool {
+		return len(registry.defaults[i].prefix) < len(registry.defaults[j].prefix)
+	})
+}
+
+// ClearDefaults removes every registered default.
+func ClearDefaults() {
+	registry.mu.Lock()
+	registry.defaults = nil
+	registry.mu.Unlock()
+}
+
+// Experiments lists every experiment that has been created with New, sorted
+// by name.
+func Experiments() []ExperimentInfo {
+	var infos []ExperimentInfo
+	registry.experiments.Range(func(key, value interface{}) bool {
+		stats := value.(*experimentStats)
+		infos = append(infos, ExperimentInfo{
+			Name:    key.(string),
+			Runs:    atomic.LoadInt64(&stats.runs),
+			Skips:   atomic.LoadInt64(&stats.skips),
+			Enabled: atomic.LoadInt32(&stats.enabled) == 1,
+		})
+		return true
+	})
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// defaultsFor merges the defaults for every prefix of name.
+func defaultsFor(name string) Defaults {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	var d Defaults
+	for _, r := range registry.defaults {
+		if !strings.HasPrefix(name, r.prefix) {
+			continue
+		}
+
+		if r.defaults.Publish != nil {
+			d.Publish = r.defaults.Publish
+		}
+		if r.defaults.ReportErrors != nil {
+			d.ReportErrors = r.defaults.ReportErrors
+		}
+		if r.defaults.Compare != nil {
+			d.Compare = r.defaults.Compare
+		}
+		if r.defaults.Rollout != nil {
+			d.Rollout = r.defaults.Rollout
+		}
+		if r.defaults.Guard != nil {
+			d.Guard = r.defaults.Guard
+		}
+	}
+	return d
+}
+
+func applyDefaults[T any](e *TypedExperiment[T]) {
+	d := defaultsFor(e.Name)
+
+	if d.Publish != nil {
+		e.Publish(func(r TypedResult[T]) error {
+			return d.Publish(untypedResult(r))
+		})
+	}
+
+	if d.ReportErrors != nil {
+		e.ReportErrors(d.ReportErrors)
+	}
+
+	if d.Compare != nil {
+		e.Compare(CompareTyped[T](d.Compare))
+	}
+
+	if d.Rollout != nil {
+		e.Rollout = d.Rollout
+	}
+
+	if d.Guard != nil {
+		e.Guard = d.Guard
+	}
+}
+
+func registeredStats(name string) *experimentStats {
+	if stats, ok := registry.experiments.Load(name); ok {
+		return stats.(*experimentStats)
+	}
+
+	stats, _ := registry.experiments.LoadOrStore(name, &experimentStats{})
+	return stats.(*experimentStats)
+}
+
+func trackRun(name string, enabled bool) {
+	stats := registeredStats(name)
+	if enabled {
+		atomic.AddInt64(&stats.runs, 1)
+		atomic.StoreInt32(&stats.enabled, 1)
+	} else {
+		atomic.AddInt64(&stats.skips, 1)
+		atomic.StoreInt32(&stats.enabled, 0)
+	}
+}
+
+// untypedResult converts a typed result for untyped publishers. The untyped
+// experiment has the same name and context, and cleans values with the typed
+// experiment's Clean callback.
+func untypedResult[T any](r TypedResult[T]) Result {
+	if untyped, ok := interface{}(r).(Result); ok {
+		return untyped
+	}
+
+	typed := r.Experiment
+	e := &Experiment{
+		Name:                 typed.Name,
+		Context:              typed.Context,
+		ErrorOnMismatches:    typed.ErrorOnMismatches,
+		RecoverControlPanics: typed.RecoverControlPanics,
+		cleaner: func(v interface{}) (interface{}, error) {
+			t, _ := v.(T)
+			return typed.cleaner(t)
+		},
+	}
+
+	observations := make(map[*TypedObservation[T]]*Observation, len(r.Observations))
+	convert := func(typedObs []*TypedObservation[T]) []*Observation {
+		obs := make([]*Observation, len(typedObs))
+		for i, o := range typedObs {
+			if converted, ok := observations[o]; ok {
+				obs[i] = converted
+				continue
+			}
+
+			obs[i] = &Observation{
+				Experiment: e,
+				Name:       o.Name,
+				Index:      o.Index,
+				Started:    o.Started,
+				Runtime:    o.Runtime,
+				Value:      o.Value,
+				Err:        o.Err,
+				TimedOut:   o.TimedOut,
+				Diff:       o.Diff,
+				Resources:  o.Resources,
+			}
+
+			converted := obs[i]
+			if o.Err == nil {
+				converted.cleanOnce.Do(func() {
+					converted.cleaned, converted.cleanErr = o.CleanedValue()
+				})
+			}
+			observations[o] = converted
+		}
+		return obs
+	}
+
+	untyped := Result{
+		Experiment:   e,
+		Observations: convert(r.Observations),
+		Candidates:   convert(r.Candidates),
+		Ignored:      convert(r.Ignored),
+		Mismatched:   convert(r.Mismatched),
+		Errors:       r.Errors,
+	}
+
+	if r.Control != nil {
+		untyped.Control = convert([]*TypedObservation[T]{r.Control})[0]
+	}
+
+	return untyped
+}
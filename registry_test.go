@@ -0,0 +1,155 @@
+package scientist
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDefaults(t *testing.T) {
+	defer ClearDefaults()
+
+	var published []string
+	var reported []string
+	SetDefaults("", Defaults{
+		Publish: func(r Result) error {
+			published = append(published, "all:"+r.Experiment.Name)
+			return errors.New("publish")
+		},
+		ReportErrors: func(errs ...ResultError) {
+			for _, err := range errs {
+				reported = append(reported, err.Experiment)
+			}
+		},
+	})
+
+	SetDefaults("registry.", Defaults{
+		Publish: func(r Result) error {
+			published = append(published, "registry:"+r.Experiment.Name)
+			return nil
+		},
+		Compare: func(control, candidate interface{}) (bool, error) {
+			return true, nil
+		},
+	})
+
+	for _, name := range []string{"registry.a", "other"} {
+		e := New(name)
+		e.Use(func() (interface{}, error) {
+			return 1, nil
+		})
+		e.Try(func() (interface{}, error) {
+			return 2, nil
+		})
+		e.ErrorOnMismatches = true
+		_, err := e.Run()
+
+		if name == "other" && err == nil {
+			t.Errorf("Expected %q to use the default comparator", name)
+		}
+
+		if name == "registry.a" && err != nil {
+			t.Errorf("Expected %q to use the registered comparator: %v", name, err)
+		}
+	}
+
+	if len(published) != 2 || published[0] != "registry:registry.a" || published[1] != "all:other" {
+		t.Errorf("Unexpected published experiments: %v", published)
+	}
+
+	if len(reported) != 1 || reported[0] != "other" {
+		t.Errorf("Unexpected reported experiments: %v", reported)
+	}
+}
+
+func TestDefaultsTyped(t *testing.T) {
+	defer ClearDefaults()
+
+	r := NewRollout(0)
+	var published *Result
+	SetDefaults("registry.typed", Defaults{
+		Rollout: r,
+		Publish: func(r Result) error {
+			published = &r
+			return nil
+		},
+	})
+
+	ran := false
+	e := NewTyped[string]("registry.typed")
+	e.Use(func() (string, error) {
+		return "a", nil
+	})
+	e.Try(func() (string, error) {
+		ran = true
+		return "b", nil
+	})
+	e.Clean(func(v string) (interface{}, error) {
+		return v + "!", nil
+	})
+
+	e.Run()
+	if ran || published != nil {
+		t.Errorf("Expected rollout to disable the experiment")
+	}
+
+	r.Percent = 100
+	e.Run()
+	if !ran || published == nil {
+		t.Fatalf("Expected rollout to enable the experiment")
+	}
+
+	if published.Experiment.Name != "registry.typed" || !published.IsMismatched() {
+		t.Errorf("Unexpected published result: %+v", published)
+	}
+
+	if published.Control.Value != "a" || published.Mismatched[0].Value != "b" {
+		t.Errorf("Unexpected published values: %v, %v", published.Control.Value, published.Mismatched[0].Value)
+	}
+
+	if cleaned, err := published.Mismatched[0].CleanedValue(); cleaned != "b!" || err != nil {
+		t.Errorf("Unexpected cleaned value: %v, %v", cleaned, err)
+	}
+}
+
+func TestExperiments(t *testing.T) {
+	// run stats are global, so clear them for -count.
+	resetExperiments("registry.experiments", "registry.unused")
+
+	enabled := true
+	e := New("registry.experiments")
+	e.Use(func() (interface{}, error) {
+		return 1, nil
+	})
+	e.Try(func() (interface{}, error) {
+		return 1, nil
+	})
+	e.RunIf(func() (bool, error) {
+		return enabled, nil
+	})
+
+	New("registry.unused")
+
+	e.Run()
+	e.Run()
+	enabled = false
+	e.Run()
+
+	infos := make(map[string]ExperimentInfo)
+	for _, info := range Experiments() {
+		infos[info.Name] = info
+	}
+
+	if info := infos["registry.experiments"]; info.Runs != 2 || info.Skips != 1 || info.Enabled {
+		t.Errorf("Unexpected experiment info: %+v", info)
+	}
+
+	if info, ok := infos["registry.unused"]; !ok || info.Runs != 0 || info.Skips != 0 {
+		t.Errorf("Unexpected unused experiment info: %+v", info)
+	}
+}
+
+func resetExperiments(names ...string) {
+	for _, name := range names {
+		registry.experiments.Delete(name)
+	}
+}
@@ -0,0 +1,65 @@
+package scientist
+
+import (
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// ResourceUsage is what a behavior allocated and how much CPU time it used,
+// recorded when its experiment has MeasureResources set.
+type ResourceUsage struct {
+	// Bytes and Allocs are the heap bytes and objects allocated while the
+	// behavior ran.
+	Bytes  uint64 `json:"alloc_bytes"`
+	Allocs uint64 `json:"allocs"`
+
+	// CPUTime is the CPU time used by the behavior's goroutine. It doesn't
+	// include goroutines that the behavior starts, and is zero on platforms
+	// where it can't be measured.
+	CPUTime time.Duration `json:"cpu_ns"`
+
+	// Approximate is set if other measured behaviors ran at the same time, or
+	// the experiment runs behaviors concurrently or asynchronously. Allocations
+	// are counted for the whole process, so they include any made by other
+	// goroutines while the behavior ran.
+	Approximate bool `json:"approximate,omitempty"`
+}
+
+var (
+	measuring     int32
+	measureStarts uint64
+)
+
+// measureCall calls the behavior, recording its ResourceUsage. The goroutine
+// is locked to its thread so that the thread's CPU time is the goroutine's.
+func measureCall[T any](e *TypedExperiment[T], fn func() (T, error)) (T, *ResourceUsage, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	usage := &ResourceUsage{
+		Approximate: e.Concurrency > 1 || e.Async != nil,
+	}
+
+	if atomic.AddInt32(&measuring, 1) > 1 {
+		usage.Approximate = true
+	}
+	starts := atomic.AddUint64(&measureStarts, 1)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	cpu := threadCPUTime()
+
+	v, err := recoverCall(fn)
+
+	usage.CPUTime = threadCPUTime() - cpu
+	runtime.ReadMemStats(&after)
+
+	if atomic.AddInt32(&measuring, -1) > 0 || atomic.LoadUint64(&measureStarts) != starts {
+		usage.Approximate = true
+	}
+
+	usage.Bytes = after.TotalAlloc - before.TotalAlloc
+	usage.Allocs = after.Mallocs - before.Mallocs
+	return v, usage, err
+}
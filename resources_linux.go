@@ -0,0 +1,15 @@
+package scientist
+
+import (
+	"syscall"
+	"time"
+)
+
+// threadCPUTime returns the CPU time used by the current thread.
+func threadCPUTime() time.Duration {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_THREAD, &ru); err != nil {
+		return 0
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
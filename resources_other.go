@@ -0,0 +1,10 @@
+//go:build !linux
+
+package scientist
+
+import "time"
+
+// threadCPUTime can't be measured on this platform.
+func threadCPUTime() time.Duration {
+	return 0
+}
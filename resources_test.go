@@ -0,0 +1,70 @@
+package scientist
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+var resourceSink []byte
+
+func TestMeasureResources(t *testing.T) {
+	e := New("resources")
+	e.MeasureResources = true
+	e.Use(func() (interface{}, error) {
+		return 1, nil
+	})
+	e.Try(func() (interface{}, error) {
+		resourceSink = make([]byte, 1<<20)
+
+		start := time.Now()
+		for time.Since(start) < 20*time.Millisecond {
+		}
+		return 1, nil
+	})
+
+	r := Run(e, "control")
+	control, candidate := r.Control.Resources, r.Candidates[0].Resources
+	if control == nil || candidate == nil {
+		t.Fatalf("Expected resources to be measured: %v, %v", control, candidate)
+	}
+
+	if control.Bytes >= 1<<20 || control.Approximate {
+		t.Errorf("Unexpected control resources: %+v", control)
+	}
+
+	if candidate.Bytes < 1<<20 || candidate.Allocs < 1 || candidate.Approximate {
+		t.Errorf("Unexpected candidate resources: %+v", candidate)
+	}
+
+	if runtime.GOOS == "linux" && candidate.CPUTime < 5*time.Millisecond {
+		t.Errorf("Unexpected candidate CPU time: %v", candidate.CPUTime)
+	}
+
+	if rec := NewResultRecord(r); rec.Candidates[0].Resources == nil {
+		t.Errorf("Expected resources in the result record")
+	}
+}
+
+func TestMeasureResourcesConcurrently(t *testing.T) {
+	e := basicExperiment()
+	e.Concurrency = 2
+	e.MeasureResources = true
+	e.CandidateTimeout = time.Second
+
+	r := Run(e, "control")
+	for _, o := range r.Observations {
+		if o.Resources == nil || !o.Resources.Approximate {
+			t.Errorf("Expected approximate resources for %q: %+v", o.Name, o.Resources)
+		}
+	}
+}
+
+func TestMeasureResourcesDisabled(t *testing.T) {
+	r := Run(basicExperiment(), "control")
+	for _, o := range r.Observations {
+		if o.Resources != nil {
+			t.Errorf("Unexpected resources for %q: %+v", o.Name, o.Resources)
+		}
+	}
+}
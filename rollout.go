@@ -0,0 +1,109 @@
+package scientist
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sync"
+)
+
+// Rollout runs an experiment for a percentage of calls. It's safe to share a
+// Rollout between goroutines, as long as its fields aren't changed.
+//
+//	rollout := scientist.NewRollout(10)
+//	rollout.Key = "user"
+//	experiment.Rollout = rollout
+type Rollout struct {
+	// Percent is the percentage of calls that run the experiment, from 0 to
+	// 100.
+	Percent float64
+
+	// Key is an experiment Context key used for sticky bucketing. Calls with
+	// the same value for this key always land in the same bucket. Calls
+	// without it are bucketed randomly.
+	Key string
+
+	// Allow and Deny list Context values for Key that always or never run the
+	// experiment. Deny wins if a value is in both.
+	Allow []string
+	Deny  []string
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func NewRollout(percent float64) *Rollout {
+	return &Rollout{Percent: percent}
+}
+
+// RandSource sets the source used to bucket calls without a sticky key.
+func (r *Rollout) RandSource(src rand.Source) {
+	r.mu.Lock()
+	r.rand = rand.New(src)
+	r.mu.Unlock()
+}
+
+// RunIf is a RunIf callback for RunIfContext. It reads the experiment's
+// Context each time it runs, so values can be added after it is set.
+func (r *Rollout) RunIf(name string, context map[string]string) (bool, error) {
+	return r.Enabled(name, context), nil
+}
+
+// Enabled checks if the named experiment should run with the given context.
+func (r *Rollout) Enabled(name string, context map[string]string) bool {
+	value, sticky := context[r.Key]
+	sticky = sticky && r.Key != ""
+
+	if sticky {
+		if containsString(r.Deny, value) {
+			return false
+		}
+
+		if containsString(r.Allow, value) {
+			return true
+		}
+	}
+
+	if r.Percent <= 0 {
+		return false
+	}
+
+	if r.Percent >= 100 {
+		return true
+	}
+
+	if sticky {
+		return RolloutBucket(name, value) < r.Percent
+	}
+
+	return r.random()*100 < r.Percent
+}
+
+func (r *Rollout) random() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.rand == nil {
+		return rand.Float64()
+	}
+	return r.rand.Float64()
+}
+
+// RolloutBucket hashes an experiment name and sticky value into a bucket from
+// 0 up to 100. The experiment name is included so that the same value lands in
+// different buckets for different experiments.
+func RolloutBucket(name, value string) float64 {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(value))
+	return float64(h.Sum32()%10000) / 100
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package scientist
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestRolloutPercent(t *testing.T) {
+	r := NewRollout(25)
+	r.RandSource(rand.NewSource(1))
+
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		if r.Enabled("percent", nil) {
+			enabled += 1
+		}
+	}
+
+	if enabled < 200 || enabled > 300 {
+		t.Errorf("Expected about 250/1000 calls to be enabled, got %d", enabled)
+	}
+
+	again := NewRollout(25)
+	again.RandSource(rand.NewSource(1))
+	repeated := 0
+	for i := 0; i < 1000; i++ {
+		if again.Enabled("percent", nil) {
+			repeated += 1
+		}
+	}
+
+	if repeated != enabled {
+		t.Errorf("Expected the same seed to enable %d calls, got %d", enabled, repeated)
+	}
+}
+
+func TestRolloutBounds(t *testing.T) {
+	context := map[string]string{"user": "1"}
+	for _, key := range []string{"", "user"} {
+		none := NewRollout(0)
+		none.Key = key
+		all := NewRollout(100)
+		all.Key = key
+
+		for i := 0; i < 100; i++ {
+			if none.Enabled("bounds", context) {
+				t.Fatalf("Expected 0%% rollout with key %q to be disabled", key)
+			}
+
+			if !all.Enabled("bounds", context) {
+				t.Fatalf("Expected 100%% rollout with key %q to be enabled", key)
+			}
+		}
+	}
+}
+
+func TestRolloutSticky(t *testing.T) {
+	r := NewRollout(30)
+	r.Key = "user"
+
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		context := map[string]string{"user": fmt.Sprintf("%d", i)}
+		first := r.Enabled("sticky", context)
+		for j := 0; j < 5; j++ {
+			if r.Enabled("sticky", context) != first {
+				t.Fatalf("Expected user %d to stay in the same bucket", i)
+			}
+		}
+
+		if first {
+			enabled += 1
+		}
+	}
+
+	if enabled < 250 || enabled > 350 {
+		t.Errorf("Expected about 300/1000 users to be enabled, got %d", enabled)
+	}
+
+	if RolloutBucket("a", "1") == RolloutBucket("b", "1") {
+		t.Errorf("Expected experiments to bucket values differently")
+	}
+}
+
+func TestRolloutAllowDeny(t *testing.T) {
+	r := NewRollout(0)
+	r.Key = "user"
+	r.Allow = []string{"staff", "both"}
+	r.Deny = []string{"both"}
+
+	allowed := map[string]bool{"staff": true, "both": false, "other": false}
+	for user, expected := range allowed {
+		if actual := r.Enabled("lists", map[string]string{"user": user}); actual != expected {
+			t.Errorf("Expected %q to be enabled=%v", user, expected)
+		}
+	}
+
+	r.Percent = 100
+	if r.Enabled("lists", map[string]string{"user": "both"}) {
+		t.Errorf("Expected denied user to be disabled")
+	}
+}
+
+func TestExperimentRollout(t *testing.T) {
+	r := NewRollout(0)
+	r.Key = "user"
+	r.Allow = []string{"staff"}
+
+	ran := false
+	e := New("rollout")
+	e.Use(func() (interface{}, error) {
+		return 1, nil
+	})
+	e.Try(func() (interface{}, error) {
+		ran = true
+		return 1, nil
+	})
+	e.Rollout = r
+
+	e.Run()
+	if ran {
+		t.Errorf("Expected candidate not to run")
+	}
+
+	e.Context["user"] = "staff"
+	e.Run()
+	if !ran {
+		t.Errorf("Expected candidate to run for allowed user")
+	}
+}
+
+func TestRolloutRunIf(t *testing.T) {
+	r := NewRollout(0)
+	r.Key = "user"
+	r.Allow = []string{"staff"}
+
+	d := Define("rollout.run_if", func(e *Experiment) {
+		e.RunIfContext(r.RunIf)
+	})
+
+	for user, expected := range map[string]bool{"staff": true, "other": false} {
+		ran := false
+		e := d.New()
+		e.Use(func() (interface{}, error) {
+			return 1, nil
+		})
+		e.Try(func() (interface{}, error) {
+			ran = true
+			return 1, nil
+		})
+		e.Context["user"] = user
+
+		e.Run()
+		if ran != expected {
+			t.Errorf("Expected candidate to run for %q: %v", user, expected)
+		}
+	}
+}
@@ -1,7 +1,13 @@
 package scientist
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -10,73 +16,293 @@ const (
 	candidateBehavior = "candidate"
 )
 
-type Observation struct {
-	Experiment *Experiment
+var (
+	// ErrCandidateTimeout is the error for a candidate observation that ran
+	// longer than the experiment's CandidateTimeout.
+	ErrCandidateTimeout = errors.New("[scientist] candidate timed out")
+
+	// ErrBehaviorNotFound is wrapped by the error for running a behavior that
+	// the experiment doesn't have.
+	ErrBehaviorNotFound = errors.New("[scientist] behavior not found")
+
+	// ErrExperimentDisabled is wrapped by the error for running a behavior that
+	// the experiment doesn't have, when the experiment is disabled by RunIf,
+	// Rollout, or Guard. Without candidates running, there's nothing else to
+	// return.
+	ErrExperimentDisabled = errors.New("[scientist] experiment disabled")
+)
+
+// Observation is the untyped observation recorded by an *Experiment.
+type Observation = TypedObservation[interface{}]
+
+type TypedObservation[T any] struct {
+	Experiment *TypedExperiment[T]
 	Name       string
+	Index      int
 	Started    time.Time
 	Runtime    time.Duration
-	Value      interface{}
+	Value      T
 	Err        error
+	TimedOut   bool
+
+	// Diff lists the differences from the control for mismatched candidates.
+	Diff Diff
+
+	// Resources is set if the experiment has MeasureResources set.
+	Resources *ResourceUsage
+
+	cleanOnce sync.Once
+	cleaned   interface{}
+	cleanErr  error
 }
 
-func (o *Observation) CleanedValue() (interface{}, error) {
-	return o.Experiment.cleaner(o.Value)
+// CleanedValue runs the experiment's Clean callback on the observation's
+// value. The cleaned value is cached, so the callback runs once per
+// observation. Run cleans every observation that didn't return an error.
+func (o *TypedObservation[T]) CleanedValue() (interface{}, error) {
+	o.cleanOnce.Do(func() {
+		o.cleaned, o.cleanErr = recoverCall(func() (interface{}, error) {
+			return o.Experiment.cleaner(o.Value)
+		})
+	})
+	return o.cleaned, o.cleanErr
+}
+
+// comparedValue returns the value that Compare and Ignore callbacks receive.
+// With CleanBeforeCompare, it's the cleaned value, which must be a T.
+func (o *TypedObservation[T]) comparedValue() (T, error) {
+	if !o.Experiment.CleanBeforeCompare || o.Err != nil {
+		return o.Value, nil
+	}
+
+	var zero T
+	cleaned, err := o.CleanedValue()
+	if err != nil {
+		return zero, err
+	}
+
+	if cleaned == nil {
+		return zero, nil
+	}
+
+	v, ok := cleaned.(T)
+	if !ok {
+		return zero, fmt.Errorf("[scientist] cleaned value for %q is a %T, not a %T", o.Name, cleaned, zero)
+	}
+	return v, nil
 }
 
-type Result struct {
-	Experiment   *Experiment
-	Control      *Observation
-	Observations []*Observation
-	Candidates   []*Observation
-	Ignored      []*Observation
-	Mismatched   []*Observation
+// Result is the untyped result published by an *Experiment.
+type Result = TypedResult[interface{}]
+
+type TypedResult[T any] struct {
+	Experiment   *TypedExperiment[T]
+	Control      *TypedObservation[T]
+	Observations []*TypedObservation[T]
+	Candidates   []*TypedObservation[T]
+	Ignored      []*TypedObservation[T]
+	Mismatched   []*TypedObservation[T]
 	Errors       []ResultError
 }
 
-func (r Result) IsMatched() bool {
+func (r TypedResult[T]) IsMatched() bool {
 	if r.IsMismatched() || r.IsIgnored() {
 		return false
 	}
 	return true
 }
 
-func (r Result) IsMismatched() bool {
+func (r TypedResult[T]) IsMismatched() bool {
 	return len(r.Mismatched) > 0
 }
 
-func (r Result) IsIgnored() bool {
+func (r TypedResult[T]) IsIgnored() bool {
 	return len(r.Ignored) > 0
 }
 
-func Run(e *Experiment, name string) Result {
-	r := Result{Experiment: e}
-	if err := e.beforeRun(); err != nil {
-		r.Errors = append(r.Errors, e.resultErr("before_run", err))
+// Diffs returns the differences from the control for each mismatched
+// candidate, by name.
+func (r TypedResult[T]) Diffs() map[string]Diff {
+	diffs := make(map[string]Diff, len(r.Mismatched))
+	for _, o := range r.Mismatched {
+		diffs[o.Name] = o.Diff
 	}
+	return diffs
+}
+
+func Run[T any](e *TypedExperiment[T], name string) TypedResult[T] {
+	return RunCtx(context.Background(), e, name)
+}
+
+func RunCtx[T any](ctx context.Context, e *TypedExperiment[T], name string) TypedResult[T] {
+	r, names := startRun(e, name)
 
-	numCandidates := len(e.behaviors) - 1
-	r.Control = observe(e, name, e.behaviors[name])
-	r.Candidates = make([]*Observation, numCandidates)
-	r.Ignored = make([]*Observation, 0, numCandidates)
-	r.Mismatched = make([]*Observation, 0, numCandidates)
-	r.Observations = make([]*Observation, numCandidates+1)
-	r.Observations[0] = r.Control
+	order := observeOrder(e, name, names, 0)
+	observeAll(ctx, e, name, names, order, 0, r.Observations)
+	finishRun(e, &r)
+	return r
+}
+
+// runAsync observes the control behavior, and queues the candidates on the
+// experiment's AsyncPool.
+func runAsync[T any](ctx context.Context, e *TypedExperiment[T], name string) *TypedObservation[T] {
+	r, names := startRun(e, name)
+	control := observe(ctx, e, name, 0)
+	r.Observations[0] = control
+
+	// candidates keep running after the caller's context is canceled, once
+	// Run has returned the control value.
+	ctx = context.WithoutCancel(ctx)
+
+	e.Async.submit(func() {
+		order := observeOrder(e, name, names, 1)
+		observeAll(ctx, e, name, names, order, 1, r.Observations)
+		finishRun(e, &r)
+	}, func(err error) {
+		e.errorReporter(e.resultErr(OperationAsync, err))
+	})
+
+	return control
+}
+
+func startRun[T any](e *TypedExperiment[T], name string) (TypedResult[T], []string) {
+	r := TypedResult[T]{Experiment: e}
+	if err := recoverErr(e.beforeRun); err != nil {
+		r.Errors = append(r.Errors, e.resultErr(OperationBeforeRun, err))
+	}
+
+	names := candidateNames(e, name)
+	numCandidates := len(names)
+	r.Candidates = make([]*TypedObservation[T], numCandidates)
+	r.Ignored = make([]*TypedObservation[T], 0, numCandidates)
+	r.Mismatched = make([]*TypedObservation[T], 0, numCandidates)
+	r.Observations = make([]*TypedObservation[T], numCandidates+1)
+	return r, names
+}
+
+// observeOrder returns the positions in Observations to observe, starting
+// with first. Observations[0] is the control, followed by candidates in name
+// order. They're observed in a random order to cancel out any bias from things
+// like cache warming, unless the experiment has a fixed Order.
+func observeOrder[T any](e *TypedExperiment[T], name string, names []string, first int) []int {
+	order := make([]int, len(names)+1-first)
+	for i := range order {
+		order[i] = i + first
+	}
+
+	if e.order == nil {
+		e.shuffle(len(order), func(i, j int) {
+			order[i], order[j] = order[j], order[i]
+		})
+		return order
+	}
 
-	i := 0
-	for bname, b := range e.behaviors {
-		if bname == name {
+	behaviorName := func(i int) string {
+		if i == 0 {
+			return name
+		}
+		return names[i-1]
+	}
+
+	rank := func(i int) int {
+		for r, n := range e.order {
+			if n == behaviorName(i) {
+				return r
+			}
+		}
+		return len(e.order)
+	}
+
+	// unlisted behaviors, including the control, run in name order.
+	sort.Slice(order, func(i, j int) bool {
+		ri, rj := rank(order[i]), rank(order[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return behaviorName(order[i]) < behaviorName(order[j])
+	})
+	return order
+}
+
+// observeAll runs the behaviors for the given positions in obs, in order.
+// Position 0 is the control, and position i is the candidate names[i-1].
+func observeAll[T any](ctx context.Context, e *TypedExperiment[T], name string, names []string, order []int, firstIndex int, obs []*TypedObservation[T]) {
+	behaviorName := func(i int) string {
+		if i == 0 {
+			return name
+		}
+		return names[i-1]
+	}
+
+	timeout := func(i int) time.Duration {
+		if i == 0 {
+			return 0
+		}
+		return e.CandidateTimeout
+	}
+
+	if e.Concurrency < 2 {
+		for index, i := range order {
+			o := observe(ctx, e, behaviorName(i), timeout(i))
+			o.Index = firstIndex + index
+			obs[i] = o
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, e.Concurrency)
+	for index, i := range order {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(index, i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			o := observe(ctx, e, behaviorName(i), timeout(i))
+			o.Index = firstIndex + index
+			obs[i] = o
+		}(index, i)
+	}
+	wg.Wait()
+}
+
+func finishRun[T any](e *TypedExperiment[T], r *TypedResult[T]) {
+	r.Control = r.Observations[0]
+	copy(r.Candidates, r.Observations[1:])
+
+	cleanFailed := make(map[*TypedObservation[T]]bool)
+	for _, o := range r.Observations {
+		if o.Err != nil {
 			continue
 		}
 
-		c := observe(e, bname, b)
-		r.Candidates[i] = c
-		i += 1
-		r.Observations[i] = c
+		_, err := o.CleanedValue()
+		if err == nil {
+			_, err = o.comparedValue()
+		}
+
+		if err != nil {
+			cleanFailed[o] = e.CleanBeforeCompare
+			r.Errors = append(r.Errors, e.resultErr(OperationClean, err))
+		}
+	}
+
+	for _, c := range r.Candidates {
+		// with CleanBeforeCompare, a candidate can't match if either value
+		// couldn't be cleaned.
+		if cleanFailed[r.Control] || cleanFailed[c] {
+			c.Diff = diffObservations(r.Control, c)
+			r.Mismatched = append(r.Mismatched, c)
+			continue
+		}
 
 		ok, err := matching(e, r.Control, c)
 		if err != nil {
 			ok = false
-			r.Errors = append(r.Errors, e.resultErr("compare", err))
+			r.Errors = append(r.Errors, e.resultErr(OperationCompare, err))
 		}
 
 		if ok {
@@ -86,45 +312,95 @@ func Run(e *Experiment, name string) Result {
 		ignored, err := ignoring(e, r.Control, c)
 		if err != nil {
 			ignored = false
-			r.Errors = append(r.Errors, e.resultErr("ignore", err))
+			r.Errors = append(r.Errors, e.resultErr(OperationIgnore, err))
 		}
 
 		if ignored {
 			r.Ignored = append(r.Ignored, c)
 		} else {
+			c.Diff = diffObservations(r.Control, c)
 			r.Mismatched = append(r.Mismatched, c)
 		}
 	}
 
-	if err := e.publisher(r); err != nil {
-		r.Errors = append(r.Errors, e.resultErr("publish", err))
+	if e.Guard != nil {
+		event, err := e.Guard.record(e.Name, newGuardSample(e.Guard, *r))
+		if event != nil {
+			r.Errors = append(r.Errors, e.resultErr(OperationGuard, *event))
+		}
+		if err != nil {
+			r.Errors = append(r.Errors, e.resultErr(OperationGuard, err))
+		}
+	}
+
+	if err := recoverErr(func() error { return e.publisher(*r) }); err != nil {
+		var errs PublisherErrors
+		if errors.As(err, &errs) {
+			for _, perr := range errs {
+				r.Errors = append(r.Errors, e.resultErr(OperationPublish, perr))
+			}
+		} else {
+			r.Errors = append(r.Errors, e.resultErr(OperationPublish, err))
+		}
 	}
 
 	if len(r.Errors) > 0 {
 		e.errorReporter(r.Errors...)
 	}
+}
 
-	return r
+// diffObservations diffs the compared values of the control and candidate, or
+// their errors if either returned one.
+func diffObservations[T any](control, candidate *TypedObservation[T]) Diff {
+	if control.Err != nil || candidate.Err != nil {
+		return Diff{{Path: "(error)", Control: control.Err, Candidate: candidate.Err}}
+	}
+
+	controlValue, controlErr := control.comparedValue()
+	candidateValue, candidateErr := candidate.comparedValue()
+	if controlErr != nil || candidateErr != nil {
+		return DiffValues(control.Value, candidate.Value)
+	}
+	return DiffValues(controlValue, candidateValue)
+}
+
+func candidateNames[T any](e *TypedExperiment[T], name string) []string {
+	names := make([]string, 0, len(e.behaviors))
+	for _, b := range e.behaviors {
+		if b.name != name {
+			names = append(names, b.name)
+		}
+	}
+	sort.Strings(names)
+	return names
 }
 
-func matching(e *Experiment, control, candidate *Observation) (bool, error) {
+func matching[T any](e *TypedExperiment[T], control, candidate *TypedObservation[T]) (bool, error) {
 	// neither returned errors
 	if control.Err == nil && candidate.Err == nil {
-		return e.comparator(control.Value, candidate.Value)
+		controlValue, _ := control.comparedValue()
+		candidateValue, _ := candidate.comparedValue()
+		return recoverCall(func() (bool, error) {
+			return e.comparator(controlValue, candidateValue)
+		})
 	}
 
 	// both returned errors
 	if control.Err != nil && candidate.Err != nil {
-		return control.Err.Error() == candidate.Err.Error(), nil
+		return recoverCall(func() (bool, error) {
+			return e.errComparator(control.Err, candidate.Err)
+		})
 	}
 
 	// returned different errors
 	return false, nil
 }
 
-func ignoring(e *Experiment, control, candidate *Observation) (bool, error) {
+func ignoring[T any](e *TypedExperiment[T], control, candidate *TypedObservation[T]) (bool, error) {
 	for _, i := range e.ignores {
-		ok, err := i(control.Value, candidate.Value)
+		ok, err := recoverCall(func() (bool, error) {
+			return i(control, candidate)
+		})
 		if err != nil {
 			return false, err
 		}
@@ -137,36 +413,144 @@ func ignoring(e *Experiment, control, candidate *Observation) (bool, error) {
 	return false, nil
 }
 
-func behaviorNotFound(e *Experiment, name string) error {
-	return fmt.Errorf("Behavior %q not found for experiment %q", name, e.Name)
+func behaviorNotFound[T any](e *TypedExperiment[T], name string) error {
+	return fmt.Errorf("Behavior %q not found for experiment %q: %w", name, e.Name, ErrBehaviorNotFound)
 }
 
-func observe(e *Experiment, name string, b behaviorFunc) *Observation {
-	o := &Observation{
+// observe runs a behavior. If timeout is set, the behavior's context is
+// canceled and the observation is marked as timed out once it expires.
+func observe[T any](ctx context.Context, e *TypedExperiment[T], name string, timeout time.Duration) *TypedObservation[T] {
+	o := &TypedObservation[T]{
 		Experiment: e,
 		Name:       name,
 		Started:    time.Now(),
 	}
 
-	if b == nil {
-		b = e.behaviors[name]
-	}
-
-	if b == nil {
+	b, ok := e.behavior(name)
+	if !ok {
 		o.Runtime = time.Since(o.Started)
 		o.Err = behaviorNotFound(e, name)
-	} else {
-		v, err := b()
+		return o
+	}
+
+	call := func() (T, *ResourceUsage, error) {
+		fn := func() (T, error) {
+			return b.call(ctx)
+		}
+
+		if e.MeasureResources {
+			return measureCall(e, fn)
+		}
+
+		v, err := recoverCall(fn)
+		return v, nil, err
+	}
+
+	if timeout <= 0 {
+		v, usage, err := call()
 		o.Runtime = time.Since(o.Started)
 		o.Value = v
 		o.Err = err
+		o.Resources = usage
+		return o
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type observed struct {
+		value T
+		usage *ResourceUsage
+		err   error
+	}
+
+	done := make(chan observed, 1)
+	go func() {
+		v, usage, err := call()
+		done <- observed{v, usage, err}
+	}()
+
+	select {
+	case obs := <-done:
+		o.Runtime = time.Since(o.Started)
+		o.Value = obs.value
+		o.Err = obs.err
+		o.Resources = obs.usage
+	case <-ctx.Done():
+		o.Runtime = time.Since(o.Started)
+		o.Err = ctx.Err()
+		if o.Err == context.DeadlineExceeded {
+			o.TimedOut = true
+			o.Err = ErrCandidateTimeout
+		}
 	}
 
 	return o
 }
 
+// recoverCall calls fn, returning any panic as a PanicError.
+func recoverCall[V any](fn func() (V, error)) (v V, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = PanicError{Value: p, Stack: debug.Stack()}
+		}
+	}()
+	return fn()
+}
+
+func recoverErr(fn func() error) error {
+	_, err := recoverCall(func() (struct{}, error) {
+		return struct{}{}, fn()
+	})
+	return err
+}
+
+// PanicError is the error recorded when a behavior or callback panics.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e PanicError) Error() string {
+	return fmt.Sprintf("[scientist] panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it's an error.
+func (e PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Operation is the experiment callback or step that a ResultError came from.
+type Operation string
+
+const (
+	OperationBeforeRun Operation = "before_run"
+	OperationClean     Operation = "clean"
+	OperationCompare   Operation = "compare"
+	OperationIgnore    Operation = "ignore"
+	OperationPublish   Operation = "publish"
+	OperationRunIf     Operation = "run_if"
+	OperationAsync     Operation = "async"
+	OperationGuard     Operation = "guard"
+)
+
+// panicSuffix is added to the operation of a callback that panicked.
+const panicSuffix = "_panic"
+
+// Panicked checks if the operation's callback panicked, such as
+// "compare_panic".
+func (o Operation) Panicked() bool {
+	return strings.HasSuffix(string(o), panicSuffix)
+}
+
+// Base returns the operation without its "_panic" suffix.
+func (o Operation) Base() Operation {
+	return Operation(strings.TrimSuffix(string(o), panicSuffix))
+}
+
 type ResultError struct {
-	Operation  string
+	Operation  Operation
 	Experiment string
 	Err        error
 }
@@ -175,10 +559,60 @@ func (e ResultError) Error() string {
 	return e.Err.Error()
 }
 
-type MismatchError struct {
-	Result Result
+func (e ResultError) Unwrap() error {
+	return e.Err
+}
+
+// ErrMismatched matches every MismatchError with errors.Is, whatever the
+// experiment's type:
+//
+//	if errors.Is(err, scientist.ErrMismatched) {
+//	  ...
+//	}
+var ErrMismatched = errors.New("[scientist] observations mismatched")
+
+// MismatchError is returned by an *Experiment with ErrorOnMismatches set.
+type MismatchError = TypedMismatchError[interface{}]
+
+type TypedMismatchError[T any] struct {
+	Result TypedResult[T]
+}
+
+// Candidates returns the names of the mismatched candidates.
+func (e TypedMismatchError[T]) Candidates() []string {
+	names := make([]string, len(e.Result.Mismatched))
+	for i, o := range e.Result.Mismatched {
+		names[i] = o.Name
+	}
+	return names
+}
+
+// Diffs returns the differences from the control for each mismatched
+// candidate, by name.
+func (e TypedMismatchError[T]) Diffs() map[string]Diff {
+	return e.Result.Diffs()
 }
 
-func (e MismatchError) Error() string {
-	return fmt.Sprintf("[scientist] experiment %q observations mismatched", e.Result.Experiment.Name)
+func (e TypedMismatchError[T]) Is(target error) bool {
+	return target == ErrMismatched
+}
+
+// maxMismatchErrorDiffs is the number of differences per candidate that are
+// included in a mismatch error message.
+const maxMismatchErrorDiffs = 5
+
+func (e TypedMismatchError[T]) Error() string {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "[scientist] experiment %q observations mismatched", e.Result.Experiment.Name)
+	for _, o := range e.Result.Mismatched {
+		fmt.Fprintf(&msg, "\n  %s:", o.Name)
+		for i, d := range o.Diff {
+			if i == maxMismatchErrorDiffs {
+				fmt.Fprintf(&msg, "\n    ... and %d more", len(o.Diff)-i)
+				break
+			}
+			fmt.Fprintf(&msg, "\n    %s", d)
+		}
+	}
+	return msg.String()
 }
@@ -1,10 +1,15 @@
 package scientist
 
 import (
+	"errors"
+	"fmt"
+	"math/rand"
 	"reflect"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func basicExperiment() *Experiment {
@@ -95,6 +100,136 @@ func TestRun(t *testing.T) {
 	}
 }
 
+func TestRunOrder(t *testing.T) {
+	controlFirst := 0
+	for seed := int64(0); seed < 20; seed++ {
+		e := basicExperiment()
+		e.RandSource(rand.NewSource(seed))
+		r := Run(e, "control")
+
+		if r.Control.Index == 0 {
+			controlFirst += 1
+		}
+
+		indexes := make([]int, len(r.Observations))
+		for i, o := range r.Observations {
+			indexes[i] = o.Index
+		}
+		sort.Ints(indexes)
+		if !reflect.DeepEqual(indexes, []int{0, 1, 2, 3}) {
+			t.Errorf("Bad observation indexes with seed %d: %v", seed, indexes)
+		}
+
+		candidates := make([]string, len(r.Candidates))
+		for i, o := range r.Candidates {
+			candidates[i] = o.Name
+		}
+		if !reflect.DeepEqual(candidates, []string{"candidate", "correct", "three"}) {
+			t.Errorf("Unstable candidate order with seed %d: %v", seed, candidates)
+		}
+
+		again := basicExperiment()
+		again.RandSource(rand.NewSource(seed))
+		r2 := Run(again, "control")
+		for i, o := range r2.Observations {
+			if o.Index != r.Observations[i].Index {
+				t.Errorf("Expected seed %d to repeat the order for %q", seed, o.Name)
+			}
+		}
+	}
+
+	if controlFirst == 0 || controlFirst == 20 {
+		t.Errorf("Expected control to run in random order, ran first %d/20 times", controlFirst)
+	}
+}
+
+func TestRunFixedOrder(t *testing.T) {
+	e := basicExperiment()
+	e.Order("three", "control")
+	r := Run(e, "control")
+
+	indexes := make(map[string]int, len(r.Observations))
+	for _, o := range r.Observations {
+		indexes[o.Name] = o.Index
+	}
+
+	expected := map[string]int{"three": 0, "control": 1, "candidate": 2, "correct": 3}
+	if !reflect.DeepEqual(indexes, expected) {
+		t.Errorf("Unexpected observation indexes: %v", indexes)
+	}
+}
+
+func TestRunFixedOrderUnlisted(t *testing.T) {
+	e := New("order.unlisted")
+	for _, name := range []string{"control", "beta", "alpha"} {
+		name := name
+		e.Behavior(name, func() (interface{}, error) {
+			return name, nil
+		})
+	}
+	e.Order("beta")
+	r := Run(e, "control")
+
+	indexes := make(map[string]int, len(r.Observations))
+	for _, o := range r.Observations {
+		indexes[o.Name] = o.Index
+	}
+
+	expected := map[string]int{"beta": 0, "alpha": 1, "control": 2}
+	if !reflect.DeepEqual(indexes, expected) {
+		t.Errorf("Unexpected observation indexes: %v", indexes)
+	}
+}
+
+func TestRunConcurrently(t *testing.T) {
+	var running, maxRunning int32
+	behavior := func(v int) func() (interface{}, error) {
+		return func() (interface{}, error) {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				max := atomic.LoadInt32(&maxRunning)
+				if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return v, nil
+		}
+	}
+
+	e := New("concurrent")
+	e.Concurrency = 2
+	e.Use(behavior(1))
+	e.Try(behavior(2))
+	e.Behavior("three", behavior(3))
+	e.Behavior("correct", behavior(1))
+	e.Behavior("four", behavior(4))
+
+	r := Run(e, "control")
+	if len(r.Errors) != 0 {
+		t.Errorf("Unexpected experiment errors: %v", r.Errors)
+	}
+
+	if max := atomic.LoadInt32(&maxRunning); max != 2 {
+		t.Errorf("Expected 2 behaviors to run at once, got %d", max)
+	}
+
+	if r.Control.Value != 1 {
+		t.Errorf("Bad value for 'control': %v", r.Control.Value)
+	}
+
+	candidates := make([]string, len(r.Candidates))
+	for i, o := range r.Candidates {
+		candidates[i] = o.Name
+	}
+	if !reflect.DeepEqual(candidates, []string{"candidate", "correct", "four", "three"}) {
+		t.Errorf("Unstable candidate order: %v", candidates)
+	}
+
+	assertObservationNames(t, "mismatched", r.Mismatched, []string{"candidate", "four", "three"})
+}
+
 func TestIgnore(t *testing.T) {
 	e := basicExperiment()
 	e.Ignore(func(control, candidate interface{}) (bool, error) {
@@ -181,6 +316,120 @@ func TestCustomCleaner(t *testing.T) {
 	}
 }
 
+func TestCleanOnce(t *testing.T) {
+	var cleaned int32
+	e := basicExperiment()
+	e.Behavior("broken", func() (interface{}, error) {
+		return nil, errors.New("broken")
+	})
+	e.Clean(func(v interface{}) (interface{}, error) {
+		atomic.AddInt32(&cleaned, 1)
+		if v == 3 {
+			return nil, errors.New("three")
+		}
+		return v, nil
+	})
+
+	var reported []ResultError
+	e.ReportErrors(func(errs ...ResultError) {
+		reported = append(reported, errs...)
+	})
+	e.Publish(func(r Result) error {
+		for _, o := range r.Observations {
+			o.CleanedValue()
+		}
+		return nil
+	})
+
+	r := Run(e, "control")
+	for _, o := range r.Observations {
+		o.CleanedValue()
+	}
+
+	// the broken candidate isn't cleaned until it's asked for
+	if cleaned != 5 {
+		t.Errorf("Expected each observation to be cleaned once, cleaned %d times", cleaned)
+	}
+
+	if len(reported) != 1 || reported[0].Operation != "clean" || reported[0].Err.Error() != "three" {
+		t.Errorf("Unexpected reported errors: %v", reported)
+	}
+}
+
+func TestCleanBeforeCompare(t *testing.T) {
+	e := New("cleaner")
+	e.CleanBeforeCompare = true
+	e.Use(func() (interface{}, error) {
+		return "booya", nil
+	})
+	e.Try(func() (interface{}, error) {
+		return "BOOYA", nil
+	})
+	e.Behavior("different", func() (interface{}, error) {
+		return "nope", nil
+	})
+	e.Behavior("unclean", func() (interface{}, error) {
+		return 1, nil
+	})
+	e.Clean(func(v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("not a string: %v", v)
+		}
+		return strings.ToUpper(s), nil
+	})
+
+	var ignored []interface{}
+	e.Ignore(func(control, candidate interface{}) (bool, error) {
+		ignored = append(ignored, candidate)
+		return false, nil
+	})
+
+	r := Run(e, "control")
+	assertObservationNames(t, "mismatched", r.Mismatched, []string{"different", "unclean"})
+
+	if !reflect.DeepEqual(ignored, []interface{}{"NOPE"}) {
+		t.Errorf("Expected Ignore to get cleaned values: %v", ignored)
+	}
+
+	if diffs := r.Diffs(); diffs["different"][0].Candidate != "NOPE" {
+		t.Errorf("Expected diff of cleaned values: %v", diffs)
+	}
+
+	if len(r.Errors) != 1 || r.Errors[0].Operation != "clean" {
+		t.Errorf("Unexpected errors: %v", r.Errors)
+	}
+}
+
+func TestCleanBeforeCompareTyped(t *testing.T) {
+	e := NewTyped[[]int]("cleaner.typed")
+	e.CleanBeforeCompare = true
+	e.Use(func() ([]int, error) {
+		return []int{1, 2}, nil
+	})
+	e.Try(func() ([]int, error) {
+		return []int{2, 1}, nil
+	})
+	e.Clean(func(v []int) (interface{}, error) {
+		sorted := append([]int(nil), v...)
+		sort.Ints(sorted)
+		return sorted, nil
+	})
+
+	if r := Run(e, "control"); !r.IsMatched() || len(r.Errors) != 0 {
+		t.Errorf("Expected sorted values to match: %v", r.Errors)
+	}
+
+	e.Clean(func(v []int) (interface{}, error) {
+		return len(v), nil
+	})
+
+	r := Run(e, "control")
+	if !r.IsMismatched() || len(r.Errors) != 2 || !strings.Contains(r.Errors[0].Error(), "is a int, not a []int") {
+		t.Errorf("Expected cleaned values that aren't []int to fail: %v", r.Errors)
+	}
+}
+
 func assertObservationNames(t *testing.T, key string, obs []*Observation, expected []string) {
 	actual := observationNames(obs)
 	if reflect.DeepEqual(expected, actual) {
@@ -0,0 +1,249 @@
+package scientisthttp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	scientist "github.com/technoweenie/go-scientist"
+)
+
+// Handler serves the Control handler's response to clients, and replays each
+// request to the Candidate handler as an experiment. The candidate's response
+// is recorded and compared with the control's, but never sent to the client:
+//
+//	shadow := &scientisthttp.Handler{
+//	  Experiment: scientist.DefineTyped("widgets-api", func(e *scientist.TypedExperiment[*scientisthttp.Response]) {
+//	    e.Publish(scientist.PublishRecords[*scientisthttp.Response](publisher.PublishRecord))
+//	  }),
+//	  Control:     oldHandler,
+//	  Candidate:   newHandler,
+//	  Normalizers: []scientisthttp.Normalizer{scientisthttp.IgnoreHeaders("Date"), scientisthttp.NormalizeJSON},
+//	}
+//
+// The candidate gets the same request, so it must be safe to handle requests
+// twice. Upgrade requests, such as for websockets, only go to the control.
+type Handler struct {
+	// Experiment defines the experiment that each request runs, with its
+	// callbacks and options. Each experiment's Context has the request's
+	// "method" and "path".
+	Experiment *scientist.TypedDefinition[*Response]
+
+	Control   http.Handler
+	Candidate http.Handler
+
+	// Normalizers change both responses before they're compared.
+	Normalizers []Normalizer
+
+	// MaxBodySize is the number of bytes of request and response bodies that
+	// are buffered. Requests with larger bodies skip the experiment, and larger
+	// response bodies are truncated. Defaults to DefaultMaxBodySize.
+	MaxBodySize int64
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.Experiment == nil || h.Candidate == nil || r.Header.Get("Upgrade") != "" {
+		h.Control.ServeHTTP(w, r)
+		return
+	}
+
+	body, complete, err := bufferBody(r, h.maxBodySize())
+	if err != nil || !complete {
+		h.Control.ServeHTTP(w, r)
+		return
+	}
+
+	candidateReq := r.Clone(r.Context())
+	served := false
+
+	e := h.Experiment.New()
+	e.Context["method"] = r.Method
+	e.Context["path"] = r.URL.Path
+	e.UseCtx(func(ctx context.Context) (*Response, error) {
+		served = true
+		resetBody(r, body)
+
+		rec := newRecorder(w, h.maxBodySize())
+		h.Control.ServeHTTP(rec, r)
+		resp := rec.response()
+		rec.writeTrailers()
+		return normalize(resp, h.Normalizers)
+	})
+	e.TryCtx(func(ctx context.Context) (*Response, error) {
+		req := candidateReq.WithContext(ctx)
+		resetBody(req, body)
+
+		rec := newRecorder(nil, h.maxBodySize())
+		h.Candidate.ServeHTTP(rec, req)
+		return normalize(rec.response(), h.Normalizers)
+	})
+
+	e.RunCtx(r.Context())
+
+	// RunIf failed, so nothing ran.
+	if !served {
+		resetBody(r, body)
+		h.Control.ServeHTTP(w, r)
+	}
+}
+
+func (h *Handler) maxBodySize() int64 {
+	if h.MaxBodySize > 0 {
+		return h.MaxBodySize
+	}
+	return DefaultMaxBodySize
+}
+
+// bufferBody reads up to max bytes of the request body. If the body is
+// longer, the request's body is replaced with one that reads the buffered
+// bytes followed by the rest.
+func bufferBody(r *http.Request, max int64) ([]byte, bool, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, max+1))
+	if err != nil || int64(len(body)) > max {
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		return nil, false, err
+	}
+
+	r.Body.Close()
+	resetBody(r, body)
+	return body, true, nil
+}
+
+func resetBody(r *http.Request, body []byte) {
+	if body == nil {
+		r.Body = http.NoBody
+		return
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// recorder records a handler's response. If w is set, the response is also
+// written to it.
+type recorder struct {
+	w           http.ResponseWriter
+	header      http.Header
+	snapshot    http.Header
+	status      int
+	wroteHeader bool
+	body        limitedBuffer
+}
+
+func newRecorder(w http.ResponseWriter, max int64) *recorder {
+	return &recorder{
+		w:      w,
+		header: make(http.Header),
+		body:   limitedBuffer{max: max},
+	}
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.wroteHeader {
+		return
+	}
+
+	r.wroteHeader = true
+	r.status = status
+	r.snapshot = r.header.Clone()
+
+	if r.w != nil {
+		for key, values := range r.header {
+			r.w.Header()[key] = values
+		}
+		r.w.WriteHeader(status)
+	}
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	if !r.wroteHeader {
+		if r.header.Get("Content-Type") == "" {
+			r.header.Set("Content-Type", http.DetectContentType(p))
+		}
+		r.WriteHeader(http.StatusOK)
+	}
+
+	r.body.Write(p)
+	if r.w != nil {
+		return r.w.Write(p)
+	}
+	return len(p), nil
+}
+
+func (r *recorder) Flush() {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+
+	if f, ok := r.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets the control take over the client's connection. The candidate
+// can't.
+func (r *recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := r.w.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+// writeTrailers copies the trailers that the handler set after writing the
+// header to the client's ResponseWriter.
+func (r *recorder) writeTrailers() {
+	if r.w == nil {
+		return
+	}
+
+	declared := make(map[string]bool)
+	for _, value := range r.header.Values("Trailer") {
+		for _, key := range strings.Split(value, ",") {
+			declared[http.CanonicalHeaderKey(strings.TrimSpace(key))] = true
+		}
+	}
+
+	for key, values := range r.header {
+		if declared[key] || strings.HasPrefix(key, http.TrailerPrefix) {
+			r.w.Header()[key] = values
+		}
+	}
+}
+
+// Unwrap returns the client's ResponseWriter, for http.ResponseController.
+func (r *recorder) Unwrap() http.ResponseWriter {
+	return r.w
+}
+
+func (r *recorder) response() *Response {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+
+	return &Response{
+		StatusCode: r.status,
+		Header:     r.snapshot,
+		Body:       r.body.buf.String(),
+		Truncated:  r.body.truncated,
+	}
+}
@@ -0,0 +1,337 @@
+package scientisthttp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	scientist "github.com/technoweenie/go-scientist"
+)
+
+// resultRecorder collects published results from every experiment of a
+// definition.
+type resultRecorder struct {
+	mu      sync.Mutex
+	results []scientist.TypedResult[*Response]
+}
+
+func (r *resultRecorder) define(name string, configure func(e *scientist.TypedExperiment[*Response])) *scientist.TypedDefinition[*Response] {
+	return scientist.DefineTyped(name, func(e *scientist.TypedExperiment[*Response]) {
+		e.Publish(func(res scientist.TypedResult[*Response]) error {
+			r.mu.Lock()
+			r.results = append(r.results, res)
+			r.mu.Unlock()
+			return nil
+		})
+		if configure != nil {
+			configure(e)
+		}
+	})
+}
+
+func echoHandler(prefix string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Date", prefix)
+		fmt.Fprintf(w, `{"method": %q, "body": %q, "prefix": %q}`, r.Method, body, prefix)
+	})
+}
+
+func TestHandler(t *testing.T) {
+	var rec resultRecorder
+	h := &Handler{
+		Experiment: rec.define("http", nil),
+		Control:    echoHandler("a"),
+		Candidate:  echoHandler("a"),
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/widgets", strings.NewReader("hello")))
+
+	if w.Code != 200 || w.Body.String() != `{"method": "POST", "body": "hello", "prefix": "a"}` {
+		t.Errorf("Unexpected response: %d %s", w.Code, w.Body.String())
+	}
+
+	if w.Header().Get("Date") != "a" {
+		t.Errorf("Unexpected response headers: %v", w.Header())
+	}
+
+	if len(rec.results) != 1 {
+		t.Fatalf("Unexpected results: %v", rec.results)
+	}
+
+	r := rec.results[0]
+	if !r.IsMatched() || r.Experiment.Context["method"] != "POST" || r.Experiment.Context["path"] != "/widgets" {
+		t.Errorf("Unexpected result: %+v", r)
+	}
+
+	if r.Candidates[0].Value.Body != w.Body.String() {
+		t.Errorf("Unexpected candidate response: %+v", r.Candidates[0].Value)
+	}
+}
+
+func TestHandlerNormalizers(t *testing.T) {
+	var rec resultRecorder
+	h := &Handler{
+		Experiment: rec.define("http.normalizers", nil),
+		Control:    echoHandler("a"),
+		Candidate: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Date", "b")
+			fmt.Fprintf(w, `{"prefix":"a","body":%q,"method":%q}`, body, r.Method)
+		}),
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PUT", "/", strings.NewReader("hello")))
+	if len(rec.results) != 1 || !rec.results[0].IsMismatched() {
+		t.Fatalf("Expected a mismatch without normalizers")
+	}
+
+	diffs := rec.results[0].Diffs()["candidate"]
+	if len(diffs) != 2 || diffs[0].Path != `.Header["Date"][0]` || diffs[1].Path != ".Body" {
+		t.Errorf("Unexpected diffs: %v", diffs)
+	}
+
+	h.Normalizers = []Normalizer{IgnoreHeaders("Date"), NormalizeJSON}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PUT", "/", strings.NewReader("hello")))
+	if len(rec.results) != 2 || !rec.results[1].IsMatched() {
+		t.Errorf("Expected a match with normalizers: %v", rec.results[1].Diffs())
+	}
+}
+
+func TestHandlerStatus(t *testing.T) {
+	var rec resultRecorder
+	h := &Handler{
+		Experiment: rec.define("http.status", nil),
+		Control: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.NotFound(w, r)
+		}),
+		Candidate: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "404 page not found", http.StatusOK)
+		}),
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != 404 {
+		t.Errorf("Unexpected status: %d", w.Code)
+	}
+
+	diffs := rec.results[0].Diffs()["candidate"]
+	if len(diffs) != 1 || diffs[0].Path != ".StatusCode" {
+		t.Errorf("Unexpected diffs: %v", diffs)
+	}
+}
+
+func TestHandlerFlush(t *testing.T) {
+	stream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("X-Stream", "1")
+		w.(http.Flusher).Flush()
+		fmt.Fprint(w, "data: hello\n\n")
+	})
+
+	var rec resultRecorder
+	h := &Handler{
+		Experiment: rec.define("http.flush", nil),
+		Control:    stream,
+		Candidate:  stream,
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	resp := w.Result()
+	if !w.Flushed || resp.Header.Get("Content-Type") != "text/event-stream" || resp.Header.Get("X-Stream") != "1" {
+		t.Errorf("Expected flushed response with headers: %v", resp.Header)
+	}
+
+	if len(rec.results) != 1 || !rec.results[0].IsMatched() || rec.results[0].Control.Value.Body != "data: hello\n\n" {
+		t.Errorf("Unexpected results: %+v", rec.results)
+	}
+}
+
+func TestHandlerTrailers(t *testing.T) {
+	trailers := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		fmt.Fprint(w, "widgets")
+		w.Header().Set("X-Checksum", "abc")
+		w.Header().Set(http.TrailerPrefix+"X-Count", "1")
+	})
+
+	var rec resultRecorder
+	h := &Handler{
+		Experiment: rec.define("http.trailers", nil),
+		Control:    trailers,
+		Candidate:  trailers,
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	resp := w.Result()
+	if resp.Trailer.Get("X-Checksum") != "abc" || resp.Trailer.Get("X-Count") != "1" {
+		t.Errorf("Unexpected trailers: %v", resp.Trailer)
+	}
+
+	if len(rec.results) != 1 || !rec.results[0].IsMatched() {
+		t.Errorf("Unexpected results: %+v", rec.results)
+	}
+}
+
+func TestHandlerHijack(t *testing.T) {
+	hijack := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Unexpected hijack error: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		status := "200 OK"
+		if r.Header.Get("Upgrade") != "" {
+			status = "101 Switching Protocols\r\nUpgrade: widgets\r\nConnection: Upgrade"
+		}
+		fmt.Fprintf(buf, "HTTP/1.1 %s\r\nContent-Length: 7\r\n\r\nwidgets", status)
+		buf.Flush()
+	})
+
+	candidates := 0
+	var rec resultRecorder
+	h := &Handler{
+		Experiment: rec.define("http.hijack", nil),
+		Control:    hijack,
+		Candidate: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			candidates += 1
+			if _, _, err := w.(http.Hijacker).Hijack(); err == nil {
+				t.Errorf("Expected the candidate not to hijack the connection")
+			}
+		}),
+	}
+
+	// hijacked requests aren't waited for when the server closes.
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r)
+		done <- struct{}{}
+	}))
+	defer server.Close()
+
+	for _, upgrade := range []string{"", "widgets"} {
+		conn, err := net.Dial("tcp", server.Listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: example.com\r\n")
+		if upgrade != "" {
+			fmt.Fprintf(conn, "Connection: Upgrade\r\nUpgrade: %s\r\n", upgrade)
+		}
+		fmt.Fprintf(conn, "\r\n")
+
+		br := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(br, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		// the body of a 101 response is the upgraded connection.
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode == http.StatusSwitchingProtocols {
+			body, _ = io.ReadAll(br)
+		}
+		conn.Close()
+		<-done
+
+		if string(body) != "widgets" {
+			t.Errorf("Unexpected response for upgrade %q: %d %q", upgrade, resp.StatusCode, body)
+		}
+	}
+
+	if candidates != 1 || len(rec.results) != 1 {
+		t.Errorf("Expected upgrade requests to skip the experiment: %d, %d", candidates, len(rec.results))
+	}
+}
+
+func TestHandlerMaxBodySize(t *testing.T) {
+	var rec resultRecorder
+	h := &Handler{
+		Experiment:  rec.define("http.max_body_size", nil),
+		Control:     echoHandler("a"),
+		Candidate:   echoHandler("a"),
+		MaxBodySize: 4,
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader("hello")))
+
+	if !strings.Contains(w.Body.String(), `"body": "hello"`) {
+		t.Errorf("Expected the control to read the whole body: %s", w.Body.String())
+	}
+
+	if len(rec.results) != 0 {
+		t.Errorf("Expected large request to skip the experiment: %v", rec.results)
+	}
+
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if len(rec.results) != 1 || !rec.results[0].Control.Value.Truncated || rec.results[0].Control.Value.Body != `{"me` {
+		t.Errorf("Expected truncated response: %+v", rec.results[0].Control.Value)
+	}
+}
+
+func TestHandlerRunIfError(t *testing.T) {
+	var rec resultRecorder
+	h := &Handler{
+		Experiment: rec.define("http.run_if", func(e *scientist.TypedExperiment[*Response]) {
+			e.RunIf(func() (bool, error) {
+				return false, errors.New("run_if")
+			})
+			e.ReportErrors(func(...scientist.ResultError) {})
+		}),
+		Control:   echoHandler("a"),
+		Candidate: echoHandler("b"),
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader("hello")))
+	if !strings.Contains(w.Body.String(), `"body": "hello"`) || len(rec.results) != 0 {
+		t.Errorf("Expected the control to be served without an experiment: %s", w.Body.String())
+	}
+}
+
+func TestHandlerAsync(t *testing.T) {
+	pool := scientist.NewAsyncPool(1, 10, scientist.DropNewest)
+	var rec resultRecorder
+	h := &Handler{
+		Experiment: rec.define("http.async", func(e *scientist.TypedExperiment[*Response]) {
+			e.Async = pool
+		}),
+		Control:   echoHandler("a"),
+		Candidate: echoHandler("a"),
+	}
+
+	for i := 0; i < 5; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(fmt.Sprintf("hello %d", i))))
+	}
+	pool.Flush()
+
+	if len(rec.results) != 5 {
+		t.Fatalf("Unexpected results: %d", len(rec.results))
+	}
+
+	for _, r := range rec.results {
+		if !r.IsMatched() {
+			t.Errorf("Unexpected mismatch: %v", r.Diffs())
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Package scientisthttp runs experiments on HTTP traffic. Handler shadows
+// requests to a candidate http.Handler, and Transport mirrors outbound
+// requests to a candidate backend.
+package scientisthttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// DefaultMaxBodySize is the default number of request and response body
+// bytes that are buffered for an experiment.
+const DefaultMaxBodySize = 1 << 20
+
+// Response is a recorded response. It's the value that HTTP experiments
+// compare.
+type Response struct {
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+
+	// Truncated is set if the body was longer than the maximum body size, and
+	// only the start of it was recorded.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Normalizer changes a recorded response before it's compared, such as to
+// remove a header that's always different.
+type Normalizer func(r *Response) error
+
+// IgnoreHeaders removes the given headers from responses.
+func IgnoreHeaders(names ...string) Normalizer {
+	return func(r *Response) error {
+		for _, name := range names {
+			r.Header.Del(name)
+		}
+		return nil
+	}
+}
+
+// NormalizeJSON re-encodes JSON bodies so that object keys are sorted and
+// whitespace is removed. The Content-Length header is removed, since the body
+// may change length. Bodies that aren't valid JSON are left alone.
+func NormalizeJSON(r *Response) error {
+	body := []byte(r.Body)
+	if r.Truncated || !json.Valid(body) {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("[scientist] error normalizing JSON: %w", err)
+	}
+
+	normalized, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("[scientist] error normalizing JSON: %w", err)
+	}
+
+	r.Body = string(normalized)
+	r.Header.Del("Content-Length")
+	return nil
+}
+
+func normalize(r *Response, normalizers []Normalizer) (*Response, error) {
+	for _, n := range normalizers {
+		if err := n(r); err != nil {
+			return r, err
+		}
+	}
+	return r, nil
+}
+
+// limitedBuffer keeps the first max bytes written to it.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	max       int64
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - int64(b.buf.Len()); int64(len(p)) > room {
+		b.truncated = true
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
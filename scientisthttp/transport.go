@@ -0,0 +1,184 @@
+package scientisthttp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+
+	scientist "github.com/technoweenie/go-scientist"
+)
+
+// IdempotentMethods are the request methods that Transport mirrors by default.
+// Sending one of these requests twice has the same effect as sending it once,
+// but PUT and DELETE requests still write to the candidate backend.
+var IdempotentMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodOptions,
+	http.MethodTrace,
+	http.MethodPut,
+	http.MethodDelete,
+}
+
+// Transport sends each outbound request to the Control transport, and mirrors
+// it to a candidate backend as an experiment. The caller gets the control's
+// response. The status and body of both responses are compared:
+//
+//	client := &http.Client{
+//	  Transport: &scientisthttp.Transport{
+//	    Experiment: scientist.DefineTyped("widgets-backend", func(e *scientist.TypedExperiment[*scientisthttp.Response]) {
+//	      e.Publish(scientist.PublishRecords[*scientisthttp.Response](publisher.PublishRecord))
+//	    }),
+//	    CandidateURL: &url.URL{Scheme: "https", Host: "widgets-v2.internal"},
+//	  },
+//	}
+//
+// Response headers aren't recorded, since they usually differ between
+// backends. Requests are only mirrored if CandidateURL or Candidate is set, so
+// that the control backend never gets a request twice.
+type Transport struct {
+	// Experiment defines the experiment that each request runs, with its
+	// callbacks and options. Each experiment's Context has the request's
+	// "method", "host" and "path".
+	Experiment *scientist.TypedDefinition[*Response]
+
+	// Control sends requests to their original URL. Defaults to
+	// http.DefaultTransport.
+	Control http.RoundTripper
+
+	// Candidate sends the mirrored requests. Defaults to Control, which
+	// requires CandidateURL.
+	Candidate http.RoundTripper
+
+	// CandidateURL replaces the scheme and host of mirrored requests, so they
+	// go to the candidate backend.
+	CandidateURL *url.URL
+
+	// Methods are the request methods that are mirrored. Other requests only go
+	// to the control. Defaults to IdempotentMethods. Set it to only GET, HEAD
+	// and OPTIONS if the candidate backend shares data with the control.
+	Methods []string
+
+	// Normalizers change both responses before they're compared.
+	Normalizers []Normalizer
+
+	// MaxBodySize is the number of bytes of request and response bodies that
+	// are buffered. Requests with larger bodies aren't mirrored, and larger
+	// response bodies are truncated. Defaults to DefaultMaxBodySize.
+	MaxBodySize int64
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Experiment == nil || (t.CandidateURL == nil && t.Candidate == nil) || !t.mirrors(req.Method) {
+		return t.control().RoundTrip(req)
+	}
+
+	// RoundTrippers must not modify the request, so the buffered body is set
+	// on a copy.
+	controlReq := req.Clone(req.Context())
+	body, complete, err := bufferBody(controlReq, t.maxBodySize())
+	if err != nil || !complete {
+		return t.control().RoundTrip(controlReq)
+	}
+
+	candidateReq := req.Clone(req.Context())
+	if t.CandidateURL != nil {
+		candidateReq.URL.Scheme = t.CandidateURL.Scheme
+		candidateReq.URL.Host = t.CandidateURL.Host
+		candidateReq.Host = ""
+	}
+
+	var resp *http.Response
+	var respErr error
+	served := false
+
+	e := t.Experiment.New()
+	e.Context["method"] = req.Method
+	e.Context["host"] = req.URL.Host
+	e.Context["path"] = req.URL.Path
+	e.UseCtx(func(ctx context.Context) (*Response, error) {
+		served = true
+		resp, respErr = t.control().RoundTrip(controlReq)
+		if respErr != nil {
+			return nil, respErr
+		}
+
+		buffered, err := io.ReadAll(io.LimitReader(resp.Body, t.maxBodySize()+1))
+		resp.Body = readCloser{io.MultiReader(bytes.NewReader(buffered), resp.Body), resp.Body}
+		if err != nil {
+			return nil, err
+		}
+		return t.response(resp.StatusCode, buffered)
+	})
+	e.TryCtx(func(ctx context.Context) (*Response, error) {
+		req := candidateReq.WithContext(ctx)
+		resetBody(req, body)
+
+		resp, err := t.candidate().RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		buffered, err := io.ReadAll(io.LimitReader(resp.Body, t.maxBodySize()+1))
+		if err != nil {
+			return nil, err
+		}
+		return t.response(resp.StatusCode, buffered)
+	})
+
+	e.RunCtx(req.Context())
+
+	// RunIf failed, so nothing ran.
+	if !served {
+		return t.control().RoundTrip(controlReq)
+	}
+	return resp, respErr
+}
+
+func (t *Transport) response(status int, buffered []byte) (*Response, error) {
+	r := &Response{StatusCode: status, Header: make(http.Header)}
+	if max := t.maxBodySize(); int64(len(buffered)) > max {
+		buffered = buffered[:max]
+		r.Truncated = true
+	}
+	r.Body = string(buffered)
+	return normalize(r, t.Normalizers)
+}
+
+func (t *Transport) mirrors(method string) bool {
+	methods := t.Methods
+	if methods == nil {
+		methods = IdempotentMethods
+	}
+
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Transport) control() http.RoundTripper {
+	if t.Control != nil {
+		return t.Control
+	}
+	return http.DefaultTransport
+}
+
+func (t *Transport) candidate() http.RoundTripper {
+	if t.Candidate != nil {
+		return t.Candidate
+	}
+	return t.control()
+}
+
+func (t *Transport) maxBodySize() int64 {
+	if t.MaxBodySize > 0 {
+		return t.MaxBodySize
+	}
+	return DefaultMaxBodySize
+}
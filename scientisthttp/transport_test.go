@@ -0,0 +1,308 @@
+package scientisthttp
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	scientist "github.com/technoweenie/go-scientist"
+)
+
+// backend is a test server that counts its requests.
+type backend struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []string
+}
+
+func newBackend(t *testing.T, h http.HandlerFunc) *backend {
+	b := &backend{}
+	b.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		b.mu.Lock()
+		b.requests = append(b.requests, fmt.Sprintf("%s %s %s", r.Method, r.URL.Path, body))
+		b.mu.Unlock()
+
+		r.Body = io.NopCloser(strings.NewReader(string(body)))
+		h(w, r)
+	}))
+	t.Cleanup(b.Close)
+	return b
+}
+
+func (b *backend) Requests() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.requests...)
+}
+
+func (b *backend) URL() *url.URL {
+	u, _ := url.Parse(b.Server.URL)
+	return u
+}
+
+func TestTransport(t *testing.T) {
+	control := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "control")
+		fmt.Fprint(w, "widgets")
+	})
+	candidate := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "candidate")
+		fmt.Fprint(w, "widgets")
+	})
+
+	var rec resultRecorder
+	client := &http.Client{Transport: &Transport{
+		Experiment:   rec.define("transport", nil),
+		CandidateURL: candidate.URL(),
+	}}
+
+	resp, err := client.Get(control.Server.URL + "/widgets")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 200 || string(body) != "widgets" || resp.Header.Get("Server") != "control" {
+		t.Errorf("Unexpected response: %d %q %v", resp.StatusCode, body, resp.Header)
+	}
+
+	if r := candidate.Requests(); len(r) != 1 || r[0] != "GET /widgets " {
+		t.Errorf("Unexpected candidate requests: %v", r)
+	}
+
+	if len(rec.results) != 1 {
+		t.Fatalf("Unexpected results: %v", rec.results)
+	}
+
+	r := rec.results[0]
+	if !r.IsMatched() || r.Experiment.Context["path"] != "/widgets" || r.Experiment.Context["host"] != control.URL().Host {
+		t.Errorf("Unexpected result: %+v", r)
+	}
+}
+
+func TestTransportMismatch(t *testing.T) {
+	control := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "widgets")
+	})
+	candidate := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "widgets", http.StatusInternalServerError)
+	})
+
+	var rec resultRecorder
+	client := &http.Client{Transport: &Transport{
+		Experiment:   rec.define("transport.mismatch", nil),
+		CandidateURL: candidate.URL(),
+	}}
+
+	resp, err := client.Get(control.Server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		t.Errorf("Unexpected status: %d", resp.StatusCode)
+	}
+
+	diffs := rec.results[0].Diffs()["candidate"]
+	if len(diffs) != 2 || diffs[0].Path != ".StatusCode" || diffs[1].Path != ".Body" {
+		t.Errorf("Unexpected diffs: %v", diffs)
+	}
+}
+
+func TestTransportMethods(t *testing.T) {
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}
+	control := newBackend(t, echo)
+	candidate := newBackend(t, echo)
+
+	var rec resultRecorder
+	transport := &Transport{
+		Experiment:   rec.define("transport.methods", nil),
+		CandidateURL: candidate.URL(),
+	}
+	client := &http.Client{Transport: transport}
+
+	for _, method := range []string{"POST", "PUT", "PATCH", "DELETE"} {
+		req, _ := http.NewRequest(method, control.Server.URL+"/widgets", strings.NewReader("hello"))
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "hello" {
+			t.Errorf("Unexpected %s response: %q", method, body)
+		}
+	}
+
+	if r := control.Requests(); len(r) != 4 {
+		t.Errorf("Unexpected control requests: %v", r)
+	}
+
+	if r := candidate.Requests(); len(r) != 2 || r[0] != "PUT /widgets hello" || r[1] != "DELETE /widgets hello" {
+		t.Errorf("Expected only idempotent requests to be mirrored: %v", r)
+	}
+
+	if len(rec.results) != 2 || !rec.results[0].IsMatched() || !rec.results[1].IsMatched() {
+		t.Errorf("Unexpected results: %v", rec.results)
+	}
+
+	transport.Methods = []string{"GET"}
+	req, _ := http.NewRequest("PUT", control.Server.URL+"/widgets", strings.NewReader("hello"))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if r := candidate.Requests(); len(r) != 2 {
+		t.Errorf("Expected PUT not to be mirrored: %v", r)
+	}
+}
+
+func TestTransportWithoutCandidate(t *testing.T) {
+	control := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "widgets")
+	})
+
+	var rec resultRecorder
+	client := &http.Client{Transport: &Transport{
+		Experiment: rec.define("transport.without_candidate", nil),
+	}}
+
+	resp, err := client.Get(control.Server.URL + "/widgets")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if r := control.Requests(); len(r) != 1 {
+		t.Errorf("Expected the control backend to get one request: %v", r)
+	}
+
+	if len(rec.results) != 0 {
+		t.Errorf("Expected no experiment without a candidate: %v", rec.results)
+	}
+}
+
+func TestTransportMaxBodySize(t *testing.T) {
+	control := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "widgets and gadgets")
+	})
+	candidate := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "widgets and sprockets")
+	})
+
+	var rec resultRecorder
+	client := &http.Client{Transport: &Transport{
+		Experiment:   rec.define("transport.max_body_size", nil),
+		CandidateURL: candidate.URL(),
+		MaxBodySize:  7,
+	}}
+
+	req, _ := http.NewRequest("GET", control.Server.URL, strings.NewReader("too large"))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if r := candidate.Requests(); len(r) != 0 || len(rec.results) != 0 {
+		t.Errorf("Expected large request to skip the experiment: %v", r)
+	}
+
+	resp, err = client.Get(control.Server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "widgets and gadgets" {
+		t.Errorf("Expected the whole control body: %q", body)
+	}
+
+	if len(rec.results) != 1 || !rec.results[0].IsMatched() {
+		t.Fatalf("Unexpected results: %v", rec.results)
+	}
+
+	if v := rec.results[0].Control.Value; v.Body != "widgets" || !v.Truncated {
+		t.Errorf("Unexpected control response: %+v", v)
+	}
+}
+
+func TestTransportCandidateError(t *testing.T) {
+	control := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "widgets")
+	})
+	candidate := newBackend(t, func(w http.ResponseWriter, r *http.Request) {})
+	candidate.Close()
+
+	var rec resultRecorder
+	client := &http.Client{Transport: &Transport{
+		Experiment:   rec.define("transport.candidate_error", nil),
+		CandidateURL: candidate.URL(),
+	}}
+
+	resp, err := client.Get(control.Server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "widgets" {
+		t.Errorf("Unexpected response: %q", body)
+	}
+
+	if len(rec.results) != 1 || rec.results[0].Candidates[0].Err == nil || !rec.results[0].IsMismatched() {
+		t.Errorf("Expected a candidate error: %+v", rec.results)
+	}
+}
+
+func TestTransportAsync(t *testing.T) {
+	control := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "widgets")
+	})
+	candidate := newBackend(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "widgets")
+	})
+
+	pool := scientist.NewAsyncPool(1, 10, scientist.DropNewest)
+	var rec resultRecorder
+	client := &http.Client{Transport: &Transport{
+		Experiment: rec.define("transport.async", func(e *scientist.TypedExperiment[*Response]) {
+			e.Async = pool
+		}),
+		CandidateURL: candidate.URL(),
+	}}
+
+	for i := 0; i < 5; i++ {
+		resp, err := client.Get(control.Server.URL)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		resp.Body.Close()
+	}
+	pool.Flush()
+
+	if len(rec.results) != 5 || len(candidate.Requests()) != 5 {
+		t.Fatalf("Unexpected results: %d", len(rec.results))
+	}
+
+	for _, r := range rec.results {
+		if !r.IsMatched() {
+			t.Errorf("Unexpected mismatch: %v", r.Diffs())
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package scientisttest
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	scientist "github.com/technoweenie/go-scientist"
+)
+
+// ReplayCase is the input of a captured result: the experiment's Context when
+// it ran, and the name of its control behavior.
+type ReplayCase struct {
+	Experiment string
+	Control    string
+	Context    map[string]string
+}
+
+var replays struct {
+	mu  sync.RWMutex
+	fns map[string]func(t testing.TB, c ReplayCase)
+}
+
+// RegisterReplay registers a function that sets up the named experiment's
+// control and candidate behaviors for a captured Context, so Replay can run it
+// again. Register replays in an init function of the test package:
+//
+//	func init() {
+//	  scientisttest.RegisterReplay("widget-permissions", func(context map[string]string) *scientist.Experiment {
+//	    return widgetPermissions(findWidget(context["widget"]), findUser(context["user"]))
+//	  })
+//	}
+func RegisterReplay[T any](name string, fn func(context map[string]string) *scientist.TypedExperiment[T]) {
+	replays.mu.Lock()
+	defer replays.mu.Unlock()
+
+	if replays.fns == nil {
+		replays.fns = make(map[string]func(testing.TB, ReplayCase))
+	}
+
+	replays.fns[name] = func(t testing.TB, c ReplayCase) {
+		t.Helper()
+
+		e := fn(c.Context)
+		if e == nil {
+			t.Fatalf("Replay for experiment %q returned no experiment", c.Experiment)
+		}
+
+		rec := Record(e)
+		scientist.Run(e, c.Control)
+		rec.AssertNoMismatches(t)
+		rec.AssertNoErrors(t)
+	}
+}
+
+// Replay runs every behavior of the registered experiment with the case's
+// Context, ignoring its RunIf and Rollout, and fails the test if any candidate
+// mismatches the control.
+func Replay(t testing.TB, c ReplayCase) {
+	t.Helper()
+
+	replays.mu.RLock()
+	fn, ok := replays.fns[c.Experiment]
+	replays.mu.RUnlock()
+
+	if !ok {
+		t.Fatalf("No replay registered for experiment %q", c.Experiment)
+	}
+
+	if c.Control == "" {
+		c.Control = "control"
+	}
+	fn(t, c)
+}
+
+// ReplayAll replays each case in a subtest named after its experiment.
+func ReplayAll(t *testing.T, cases []ReplayCase) {
+	t.Helper()
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Experiment, func(t *testing.T) {
+			Replay(t, c)
+		})
+	}
+}
+
+// WriteRegressionTests writes a Go test file for package pkg that replays the
+// mismatched records with ReplayAll. Records with the same experiment, control
+// and Context are only replayed once.
+func WriteRegressionTests(w io.Writer, pkg string, records []scientist.ResultRecord) error {
+	var mismatches []scientist.ResultRecord
+	seen := make(map[string]bool)
+	for _, rec := range records {
+		if rec.Status != scientist.StatusMismatched {
+			continue
+		}
+
+		key := rec.Experiment + "\x00" + rec.Control.Name + "\x00" + contextLiteral(rec.Context)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		mismatches = append(mismatches, rec)
+	}
+
+	sort.SliceStable(mismatches, func(i, j int) bool {
+		if mismatches[i].Experiment != mismatches[j].Experiment {
+			return mismatches[i].Experiment < mismatches[j].Experiment
+		}
+		return mismatches[i].Time.Before(mismatches[j].Time)
+	})
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by scientist -tests. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	fmt.Fprintf(&buf, "import (\n\"testing\"\n\n%q\n)\n\n", "github.com/technoweenie/go-scientist/scientisttest")
+	fmt.Fprintf(&buf, "func TestScientistRegressions(t *testing.T) {\nscientisttest.ReplayAll(t, []scientisttest.ReplayCase{\n")
+	for _, rec := range mismatches {
+		fmt.Fprintf(&buf, "// mismatched at %s\n", rec.Time.UTC().Format("2006-01-02T15:04:05Z"))
+		for _, c := range rec.Candidates {
+			if c.Status != scientist.StatusMismatched {
+				continue
+			}
+			for _, d := range c.Diff {
+				fmt.Fprintf(&buf, "// %s\n", oneLine(fmt.Sprintf("%s %s: %s != %s", c.Name, d.Path, d.Control, d.Candidate)))
+			}
+		}
+		fmt.Fprintf(&buf, "{Experiment: %q, Control: %q, Context: %s},\n", rec.Experiment, rec.Control.Name, contextLiteral(rec.Context))
+	}
+	fmt.Fprintf(&buf, "})\n}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("[scientist] error formatting regression tests: %w", err)
+	}
+
+	_, err = w.Write(src)
+	return err
+}
+
+// contextLiteral formats a Context as a Go map literal, with sorted keys.
+func contextLiteral(context map[string]string) string {
+	keys := make([]string, 0, len(context))
+	for key := range context {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.WriteString("map[string]string{")
+	for i, key := range keys {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(strconv.Quote(key))
+		buf.WriteString(": ")
+		buf.WriteString(strconv.Quote(context[key]))
+	}
+	buf.WriteString("}")
+	return buf.String()
+}
+
+// oneLine keeps a recorded value from ending a generated comment early.
+func oneLine(s string) string {
+	return strings.NewReplacer("\r", `\r`, "\n", `\n`).Replace(s)
+}
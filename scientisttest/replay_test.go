@@ -0,0 +1,97 @@
+package scientisttest
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+	"time"
+
+	scientist "github.com/technoweenie/go-scientist"
+)
+
+func init() {
+	RegisterReplay("scientisttest.replay", func(context map[string]string) *scientist.TypedExperiment[string] {
+		e := scientist.NewTyped[string]("scientisttest.replay")
+		e.RunIf(func() (bool, error) {
+			return false, nil
+		})
+		e.Use(func() (string, error) {
+			return context["user"], nil
+		})
+		e.Try(func() (string, error) {
+			if context["user"] == "broken" {
+				return "", nil
+			}
+			return context["user"], nil
+		})
+		return e
+	})
+}
+
+func TestReplay(t *testing.T) {
+	Replay(t, ReplayCase{Experiment: "scientisttest.replay", Context: map[string]string{"user": "1"}})
+
+	ReplayAll(t, []ReplayCase{
+		{Experiment: "scientisttest.replay", Control: "control", Context: map[string]string{"user": "2"}},
+	})
+
+	ft := &fakeT{TB: t}
+	Replay(ft, ReplayCase{Experiment: "scientisttest.replay", Context: map[string]string{"user": "broken"}})
+	if len(ft.failures) != 1 || !strings.Contains(ft.failures[0], `"broken" != ""`) {
+		t.Errorf("Expected mismatched replay to fail: %v", ft.failures)
+	}
+}
+
+func TestWriteRegressionTests(t *testing.T) {
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	mismatch := scientist.ResultRecord{
+		Experiment: "widget-permissions",
+		Time:       start,
+		Context:    map[string]string{"user": "1", "widget": "a\"b"},
+		Status:     scientist.StatusMismatched,
+		Control:    scientist.ObservationRecord{Name: "control"},
+		Candidates: []scientist.ObservationRecord{
+			{
+				Name:   "candidate",
+				Status: scientist.StatusMismatched,
+				Diff:   []scientist.DifferenceRecord{{Path: ".", Control: "true", Candidate: "\"a\nb\""}},
+			},
+		},
+	}
+
+	matched := mismatch
+	matched.Context = map[string]string{"user": "2"}
+	matched.Status = scientist.StatusMatched
+
+	other := mismatch
+	other.Experiment = "other"
+	other.Time = start.Add(time.Hour)
+
+	var buf bytes.Buffer
+	err := WriteRegressionTests(&buf, "widgets", []scientist.ResultRecord{other, mismatch, matched, mismatch})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	src := buf.String()
+	if _, err := parser.ParseFile(token.NewFileSet(), "regressions_test.go", src, 0); err != nil {
+		t.Fatalf("Generated invalid Go: %v\n%s", err, src)
+	}
+
+	for _, expected := range []string{
+		"package widgets\n",
+		"func TestScientistRegressions(t *testing.T) {",
+		`{Experiment: "widget-permissions", Control: "control", Context: map[string]string{"user": "1", "widget": "a\"b"}},`,
+		`// candidate .: true != "a\nb"`,
+	} {
+		if !strings.Contains(src, expected) {
+			t.Errorf("Expected generated tests to contain %q:\n%s", expected, src)
+		}
+	}
+
+	if strings.Count(src, "Experiment:") != 2 || strings.Index(src, `"other"`) > strings.Index(src, `"widget-permissions"`) {
+		t.Errorf("Expected one sorted case per unique mismatch:\n%s", src)
+	}
+}
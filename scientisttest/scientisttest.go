@@ -0,0 +1,141 @@
+// Package scientisttest records the results of experiments in tests, and
+// asserts on them.
+//
+//	func TestWidgetAllows(t *testing.T) {
+//	  e := widgetExperiment()
+//	  e.Order("control", "candidate")
+//	  rec := scientisttest.Record(e)
+//
+//	  e.Run()
+//	  rec.AssertNoMismatches(t)
+//	}
+package scientisttest
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	scientist "github.com/technoweenie/go-scientist"
+)
+
+// Recorder keeps every result and error from the experiments it records. It's
+// safe to share between goroutines, such as with async experiments.
+type Recorder[T any] struct {
+	mu      sync.Mutex
+	results []scientist.TypedResult[T]
+	errors  []scientist.ResultError
+}
+
+// Record sets the experiment's Publish and ReportErrors callbacks to a new
+// Recorder.
+func Record[T any](e *scientist.TypedExperiment[T]) *Recorder[T] {
+	r := &Recorder[T]{}
+	e.Publish(r.Publish)
+	e.ReportErrors(r.ReportErrors)
+	return r
+}
+
+func (r *Recorder[T]) Publish(result scientist.TypedResult[T]) error {
+	r.mu.Lock()
+	r.results = append(r.results, result)
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Recorder[T]) ReportErrors(errs ...scientist.ResultError) {
+	r.mu.Lock()
+	r.errors = append(r.errors, errs...)
+	r.mu.Unlock()
+}
+
+// Results returns the published results, in the order they were published.
+func (r *Recorder[T]) Results() []scientist.TypedResult[T] {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]scientist.TypedResult[T](nil), r.results...)
+}
+
+// Errors returns the reported errors, in the order they were reported.
+func (r *Recorder[T]) Errors() []scientist.ResultError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]scientist.ResultError(nil), r.errors...)
+}
+
+// Reset forgets every recorded result and error.
+func (r *Recorder[T]) Reset() {
+	r.mu.Lock()
+	r.results = nil
+	r.errors = nil
+	r.mu.Unlock()
+}
+
+// AssertMatched fails the test unless at least one result was published, and
+// every candidate matched the control.
+func (r *Recorder[T]) AssertMatched(t testing.TB) {
+	t.Helper()
+
+	results := r.Results()
+	if len(results) == 0 {
+		t.Errorf("Expected experiment results, none were published")
+	}
+
+	for _, result := range results {
+		if result.IsMismatched() {
+			t.Errorf("%v", scientist.TypedMismatchError[T]{Result: result})
+		}
+
+		for _, o := range result.Ignored {
+			t.Errorf("Expected %q to match in experiment %q, it was ignored", o.Name, result.Experiment.Name)
+		}
+	}
+}
+
+// AssertNoMismatches fails the test if any published result has a mismatched
+// candidate. Ignored mismatches are allowed.
+func (r *Recorder[T]) AssertNoMismatches(t testing.TB) {
+	t.Helper()
+
+	for _, result := range r.Results() {
+		if result.IsMismatched() {
+			t.Errorf("%v", scientist.TypedMismatchError[T]{Result: result})
+		}
+	}
+}
+
+// AssertCandidateError fails the test unless a published result has a
+// candidate with the given name whose error matches target with errors.Is. A
+// nil target matches any error.
+func (r *Recorder[T]) AssertCandidateError(t testing.TB, name string, target error) {
+	t.Helper()
+
+	var errs []error
+	for _, result := range r.Results() {
+		for _, o := range result.Candidates {
+			if o.Name != name || o.Err == nil {
+				continue
+			}
+
+			if target == nil || errors.Is(o.Err, target) {
+				return
+			}
+			errs = append(errs, o.Err)
+		}
+	}
+
+	if target == nil {
+		t.Errorf("Expected candidate %q to return an error", name)
+	} else {
+		t.Errorf("Expected candidate %q to return %v, got: %v", name, target, errs)
+	}
+}
+
+// AssertNoErrors fails the test if any errors were reported.
+func (r *Recorder[T]) AssertNoErrors(t testing.TB) {
+	t.Helper()
+
+	for _, err := range r.Errors() {
+		t.Errorf("Unexpected %q error in experiment %q: %v", err.Operation, err.Experiment, err.Err)
+	}
+}
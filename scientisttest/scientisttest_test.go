@@ -0,0 +1,148 @@
+package scientisttest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	scientist "github.com/technoweenie/go-scientist"
+)
+
+// fakeT records failures instead of failing the test.
+type fakeT struct {
+	testing.TB
+	failures []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+var errBroken = errors.New("broken")
+
+func experiment(candidate interface{}, err error) (*scientist.Experiment, *Recorder[interface{}]) {
+	e := scientist.New("scientisttest")
+	e.Use(func() (interface{}, error) {
+		return 1, nil
+	})
+	e.Try(func() (interface{}, error) {
+		return candidate, err
+	})
+	e.Order("control", "candidate")
+	return e, Record(e)
+}
+
+func TestRecordMatched(t *testing.T) {
+	e, rec := experiment(1, nil)
+	e.Run()
+
+	if results := rec.Results(); len(results) != 1 || results[0].Candidates[0].Value != 1 {
+		t.Errorf("Unexpected results: %v", results)
+	}
+
+	rec.AssertMatched(t)
+	rec.AssertNoMismatches(t)
+	rec.AssertNoErrors(t)
+
+	ft := &fakeT{TB: t}
+	rec.AssertCandidateError(ft, "candidate", nil)
+	if len(ft.failures) != 1 {
+		t.Errorf("Unexpected failures: %v", ft.failures)
+	}
+}
+
+func TestRecordMismatched(t *testing.T) {
+	e, rec := experiment(2, nil)
+	e.Run()
+
+	ft := &fakeT{TB: t}
+	rec.AssertMatched(ft)
+	rec.AssertNoMismatches(ft)
+	if len(ft.failures) != 2 {
+		t.Fatalf("Unexpected failures: %v", ft.failures)
+	}
+
+	if !strings.Contains(ft.failures[0], "candidate:\n    .: 1 != 2") {
+		t.Errorf("Expected failure to include the diff: %s", ft.failures[0])
+	}
+
+	rec.Reset()
+	rec.AssertNoMismatches(t)
+
+	ft = &fakeT{TB: t}
+	rec.AssertMatched(ft)
+	if len(ft.failures) != 1 {
+		t.Errorf("Expected AssertMatched to fail without results: %v", ft.failures)
+	}
+}
+
+func TestRecordIgnored(t *testing.T) {
+	e, rec := experiment(2, nil)
+	e.Ignore(func(control, candidate interface{}) (bool, error) {
+		return true, nil
+	})
+	e.Run()
+
+	rec.AssertNoMismatches(t)
+
+	ft := &fakeT{TB: t}
+	rec.AssertMatched(ft)
+	if len(ft.failures) != 1 {
+		t.Errorf("Expected ignored candidate to fail AssertMatched: %v", ft.failures)
+	}
+}
+
+func TestRecordCandidateError(t *testing.T) {
+	e, rec := experiment(nil, fmt.Errorf("wrapped: %w", errBroken))
+	e.Run()
+
+	rec.AssertCandidateError(t, "candidate", nil)
+	rec.AssertCandidateError(t, "candidate", errBroken)
+
+	ft := &fakeT{TB: t}
+	rec.AssertCandidateError(ft, "candidate", errors.New("other"))
+	rec.AssertCandidateError(ft, "missing", nil)
+	if len(ft.failures) != 2 {
+		t.Errorf("Unexpected failures: %v", ft.failures)
+	}
+}
+
+func TestRecordErrors(t *testing.T) {
+	e, rec := experiment(1, nil)
+	e.Compare(func(control, candidate interface{}) (bool, error) {
+		return false, errBroken
+	})
+	e.Run()
+
+	errs := rec.Errors()
+	if len(errs) != 1 || errs[0].Operation != "compare" {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+
+	ft := &fakeT{TB: t}
+	rec.AssertNoErrors(ft)
+	if len(ft.failures) != 1 {
+		t.Errorf("Unexpected failures: %v", ft.failures)
+	}
+}
+
+func TestRecordTyped(t *testing.T) {
+	e := scientist.NewTyped[string]("scientisttest.typed")
+	e.Use(func() (string, error) {
+		return "a", nil
+	})
+	e.Try(func() (string, error) {
+		return "a", nil
+	})
+
+	rec := Record(e)
+	e.Run()
+	rec.AssertMatched(t)
+
+	if results := rec.Results(); len(results) != 1 || results[0].Control.Value != "a" {
+		t.Errorf("Unexpected results: %v", results)
+	}
+}